@@ -0,0 +1,293 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rofleksey/dwight/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	anthropicDefaultBaseURL = "https://api.anthropic.com"
+	anthropicVersion        = "2023-06-01"
+)
+
+// AnthropicClient implements Provider on top of the native Anthropic Messages API.
+type AnthropicClient struct {
+	baseURL    string
+	token      string
+	maxTokens  int
+	httpClient *http.Client
+}
+
+type anthropicContent struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema interface{} `json:"input_schema"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	Tools     []anthropicTool    `json:"tools,omitempty"`
+	Stream    bool               `json:"stream"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+type anthropicEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message struct {
+		ID    string         `json:"id"`
+		Model string         `json:"model"`
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	ContentBlock anthropicContent `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func NewAnthropicClient(cfg *config.Config) *AnthropicClient {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = anthropicDefaultBaseURL
+	}
+	return &AnthropicClient{
+		baseURL:    strings.TrimSuffix(strings.TrimRight(baseURL, "/"), "/v1"),
+		token:      cfg.Token,
+		maxTokens:  cfg.MaxOutputTokens,
+		httpClient: &http.Client{},
+	}
+}
+
+func (a *AnthropicClient) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest, onDelta func(string)) (openai.ChatCompletionResponse, error) {
+	body, err := json.Marshal(a.convertRequest(req))
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("X-Api-Key", a.token)
+	httpReq.Header.Set("Anthropic-Version", anthropicVersion)
+
+	resp, err := a.httpClient.Do(httpReq)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return openai.ChatCompletionResponse{}, fmt.Errorf("anthropic API returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	return a.readStream(resp.Body, onDelta)
+}
+
+func (a *AnthropicClient) convertRequest(req openai.ChatCompletionRequest) anthropicRequest {
+	out := anthropicRequest{
+		Model:     req.Model,
+		MaxTokens: a.maxTokens,
+		Stream:    true,
+	}
+
+	var system []string
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case openai.ChatMessageRoleSystem:
+			system = append(system, msg.Content)
+		case openai.ChatMessageRoleAssistant:
+			var content []anthropicContent
+			if msg.Content != "" {
+				content = append(content, anthropicContent{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				content = append(content, anthropicContent{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: input})
+			}
+			out.Messages = appendAnthropicMessage(out.Messages, "assistant", content)
+		case openai.ChatMessageRoleTool:
+			out.Messages = appendAnthropicMessage(out.Messages, "user", []anthropicContent{
+				{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content},
+			})
+		default:
+			out.Messages = appendAnthropicMessage(out.Messages, "user", []anthropicContent{
+				{Type: "text", Text: msg.Content},
+			})
+		}
+	}
+	out.System = strings.Join(system, "\n\n")
+
+	for _, tool := range req.Tools {
+		if tool.Function == nil {
+			continue
+		}
+		out.Tools = append(out.Tools, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: tool.Function.Parameters,
+		})
+	}
+	return out
+}
+
+// appendAnthropicMessage merges consecutive messages of the same role, since the Messages API
+// expects tool results of one turn in a single user message.
+func appendAnthropicMessage(messages []anthropicMessage, role string, content []anthropicContent) []anthropicMessage {
+	if len(content) == 0 {
+		return messages
+	}
+	if n := len(messages); n > 0 && messages[n-1].Role == role {
+		messages[n-1].Content = append(messages[n-1].Content, content...)
+		return messages
+	}
+	return append(messages, anthropicMessage{Role: role, Content: content})
+}
+
+func (a *AnthropicClient) readStream(body io.Reader, onDelta func(string)) (openai.ChatCompletionResponse, error) {
+	var (
+		response  openai.ChatCompletionResponse
+		content   strings.Builder
+		toolCalls []openai.ToolCall
+		blocks    = make(map[int]int)
+		usage     anthropicUsage
+		finish    openai.FinishReason
+	)
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var event anthropicEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return openai.ChatCompletionResponse{}, fmt.Errorf("error parsing anthropic stream: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			response.ID = event.Message.ID
+			response.Model = event.Message.Model
+			usage = event.Message.Usage
+		case "content_block_start":
+			if event.ContentBlock.Type == "tool_use" {
+				blocks[event.Index] = len(toolCalls)
+				toolCalls = append(toolCalls, openai.ToolCall{
+					ID:       event.ContentBlock.ID,
+					Type:     openai.ToolTypeFunction,
+					Function: openai.FunctionCall{Name: event.ContentBlock.Name},
+				})
+			}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				content.WriteString(event.Delta.Text)
+				if onDelta != nil {
+					onDelta(event.Delta.Text)
+				}
+			case "input_json_delta":
+				if i, ok := blocks[event.Index]; ok {
+					toolCalls[i].Function.Arguments += event.Delta.PartialJSON
+				}
+			}
+		case "message_delta":
+			finish = anthropicFinishReason(event.Delta.StopReason)
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "error":
+			if event.Error != nil {
+				return openai.ChatCompletionResponse{}, fmt.Errorf("anthropic stream error: %s: %s", event.Error.Type, event.Error.Message)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	for i := range toolCalls {
+		if toolCalls[i].Function.Arguments == "" {
+			toolCalls[i].Function.Arguments = "{}"
+		}
+	}
+
+	promptTokens := usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens
+	response.Usage = openai.Usage{
+		PromptTokens:        promptTokens,
+		CompletionTokens:    usage.OutputTokens,
+		TotalTokens:         promptTokens + usage.OutputTokens,
+		PromptTokensDetails: &openai.PromptTokensDetails{CachedTokens: usage.CacheReadInputTokens},
+	}
+	response.Choices = []openai.ChatCompletionChoice{
+		{
+			Message: openai.ChatCompletionMessage{
+				Role:      openai.ChatMessageRoleAssistant,
+				Content:   content.String(),
+				ToolCalls: toolCalls,
+			},
+			FinishReason: finish,
+		},
+	}
+	return response, nil
+}
+
+func anthropicFinishReason(stopReason string) openai.FinishReason {
+	switch stopReason {
+	case "tool_use":
+		return openai.FinishReasonToolCalls
+	case "max_tokens":
+		return openai.FinishReasonLength
+	default:
+		return openai.FinishReasonStop
+	}
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rofleksey/dwight/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+const ollamaDefaultBaseURL = "http://localhost:11434"
+
+// OllamaClient implements Provider on top of the native Ollama /api/chat endpoint.
+type OllamaClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []openai.Tool   `json:"tools,omitempty"`
+	Stream   bool            `json:"stream"`
+}
+
+type ollamaChunk struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func NewOllamaClient(cfg *config.Config) *OllamaClient {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = ollamaDefaultBaseURL
+	}
+	return &OllamaClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{},
+	}
+}
+
+func (o *OllamaClient) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest, onDelta func(string)) (openai.ChatCompletionResponse, error) {
+	body, err := json.Marshal(convertOllamaRequest(req))
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.httpClient.Do(httpReq)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return openai.ChatCompletionResponse{}, fmt.Errorf("ollama returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	return readOllamaStream(resp.Body, onDelta)
+}
+
+func convertOllamaRequest(req openai.ChatCompletionRequest) ollamaRequest {
+	out := ollamaRequest{
+		Model:  req.Model,
+		Tools:  req.Tools,
+		Stream: true,
+	}
+
+	toolNames := make(map[string]string)
+	for _, msg := range req.Messages {
+		converted := ollamaMessage{Role: msg.Role, Content: msg.Content}
+		for _, call := range msg.ToolCalls {
+			toolNames[call.ID] = call.Function.Name
+			var tc ollamaToolCall
+			tc.Function.Name = call.Function.Name
+			tc.Function.Arguments = json.RawMessage(call.Function.Arguments)
+			if !json.Valid(tc.Function.Arguments) {
+				tc.Function.Arguments = json.RawMessage("{}")
+			}
+			converted.ToolCalls = append(converted.ToolCalls, tc)
+		}
+		if msg.Role == openai.ChatMessageRoleTool {
+			converted.ToolName = toolNames[msg.ToolCallID]
+		}
+		out.Messages = append(out.Messages, converted)
+	}
+	return out
+}
+
+func readOllamaStream(body io.Reader, onDelta func(string)) (openai.ChatCompletionResponse, error) {
+	var (
+		response  openai.ChatCompletionResponse
+		content   strings.Builder
+		toolCalls []openai.ToolCall
+		finish    = openai.FinishReasonStop
+	)
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var chunk ollamaChunk
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return openai.ChatCompletionResponse{}, fmt.Errorf("error parsing ollama stream: %w", err)
+		}
+		if chunk.Error != "" {
+			return openai.ChatCompletionResponse{}, fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+
+		response.Model = chunk.Model
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if onDelta != nil {
+				onDelta(chunk.Message.Content)
+			}
+		}
+		for _, call := range chunk.Message.ToolCalls {
+			// Ollama does not assign tool call IDs, so synthesize stable ones for tool results to refer to.
+			toolCalls = append(toolCalls, openai.ToolCall{
+				ID:   fmt.Sprintf("call_%d", len(toolCalls)),
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      call.Function.Name,
+					Arguments: string(call.Function.Arguments),
+				},
+			})
+		}
+
+		if chunk.Done {
+			response.Usage = openai.Usage{
+				PromptTokens:     chunk.PromptEvalCount,
+				CompletionTokens: chunk.EvalCount,
+				TotalTokens:      chunk.PromptEvalCount + chunk.EvalCount,
+			}
+			if chunk.DoneReason == "length" {
+				finish = openai.FinishReasonLength
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	if len(toolCalls) > 0 {
+		finish = openai.FinishReasonToolCalls
+	}
+	response.Choices = []openai.ChatCompletionChoice{
+		{
+			Message: openai.ChatCompletionMessage{
+				Role:      openai.ChatMessageRoleAssistant,
+				Content:   content.String(),
+				ToolCalls: toolCalls,
+			},
+			FinishReason: finish,
+		},
+	}
+	return response, nil
+}
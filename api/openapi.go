@@ -2,6 +2,8 @@ package api
 
 import (
 	"context"
+	"errors"
+	"io"
 
 	"github.com/rofleksey/dwight/config"
 	"github.com/sashabaranov/go-openai"
@@ -13,11 +15,40 @@ type OpenAIClient struct {
 
 func NewOpenAIClient(config *config.Config) *OpenAIClient {
 	clientConfig := openai.DefaultConfig(config.Token)
-	clientConfig.BaseURL = config.BaseURL
+	if config.BaseURL != "" {
+		clientConfig.BaseURL = config.BaseURL
+	}
 	client := openai.NewClientWithConfig(clientConfig)
 	return &OpenAIClient{client: client}
 }
 
-func (o *OpenAIClient) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
-	return o.client.CreateChatCompletion(ctx, req)
+func (o *OpenAIClient) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest, onDelta func(string)) (openai.ChatCompletionResponse, error) {
+	if onDelta == nil {
+		return o.client.CreateChatCompletion(ctx, req)
+	}
+
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, err := o.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	defer stream.Close()
+
+	var acc streamAccumulator
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return openai.ChatCompletionResponse{}, err
+		}
+
+		acc.add(chunk)
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			onDelta(chunk.Choices[0].Delta.Content)
+		}
+	}
+
+	return acc.result(), nil
 }
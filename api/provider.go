@@ -0,0 +1,30 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rofleksey/dwight/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Provider is an LLM chat completion backend. Requests and responses use the OpenAI chat format
+// as the common representation; backends with other native APIs translate to and from it.
+type Provider interface {
+	// CreateChatCompletion performs a chat completion request. If onDelta is not nil,
+	// it receives text content as it is generated.
+	CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest, onDelta func(string)) (openai.ChatCompletionResponse, error)
+}
+
+func NewProvider(cfg *config.Config) (Provider, error) {
+	switch cfg.Provider {
+	case config.ProviderOpenAI:
+		return NewOpenAIClient(cfg), nil
+	case config.ProviderAnthropic:
+		return NewAnthropicClient(cfg), nil
+	case config.ProviderOllama:
+		return NewOllamaClient(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown provider: %s", cfg.Provider)
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ScriptedResponse is one canned model turn of a ScriptedProvider.
+type ScriptedResponse struct {
+	Content   string             `json:"content"`
+	ToolCalls []ScriptedToolCall `json:"tool_calls"`
+	Usage     *openai.Usage      `json:"usage,omitempty"`
+	Error     string             `json:"error,omitempty"`
+}
+
+type ScriptedToolCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// ScriptedProvider replays a fixed list of responses in order, which makes runs of the
+// executor deterministic and independent of any real model. It is meant for tests and
+// cannot be selected in the config.
+type ScriptedProvider struct {
+	mu        sync.Mutex
+	responses []ScriptedResponse
+	next      int
+	requests  []openai.ChatCompletionRequest
+}
+
+func NewScriptedProvider(path string) (*ScriptedProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading script file: %w", err)
+	}
+
+	var responses []ScriptedResponse
+	if err := json.Unmarshal(data, &responses); err != nil {
+		return nil, fmt.Errorf("error parsing script file: %w", err)
+	}
+	return NewScriptedProviderWithResponses(responses), nil
+}
+
+func NewScriptedProviderWithResponses(responses []ScriptedResponse) *ScriptedProvider {
+	return &ScriptedProvider{responses: responses}
+}
+
+// Requests returns all requests received so far.
+func (s *ScriptedProvider) Requests() []openai.ChatCompletionRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]openai.ChatCompletionRequest(nil), s.requests...)
+}
+
+func (s *ScriptedProvider) CreateChatCompletion(_ context.Context, req openai.ChatCompletionRequest, onDelta func(string)) (openai.ChatCompletionResponse, error) {
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	if s.next >= len(s.responses) {
+		s.mu.Unlock()
+		return openai.ChatCompletionResponse{}, errors.New("scripted provider has no more responses")
+	}
+	scripted := s.responses[s.next]
+	s.next++
+	turn := s.next
+	s.mu.Unlock()
+
+	if scripted.Error != "" {
+		return openai.ChatCompletionResponse{}, errors.New(scripted.Error)
+	}
+
+	if onDelta != nil && scripted.Content != "" {
+		onDelta(scripted.Content)
+	}
+
+	message := openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleAssistant,
+		Content: scripted.Content,
+	}
+	finish := openai.FinishReasonStop
+	for i, call := range scripted.ToolCalls {
+		args := string(call.Arguments)
+		if args == "" {
+			args = "{}"
+		}
+		message.ToolCalls = append(message.ToolCalls, openai.ToolCall{
+			ID:       fmt.Sprintf("call_%d_%d", turn, i),
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: call.Name, Arguments: args},
+		})
+		finish = openai.FinishReasonToolCalls
+	}
+
+	response := openai.ChatCompletionResponse{
+		ID:      fmt.Sprintf("scripted-%d", turn),
+		Object:  "chat.completion",
+		Model:   req.Model,
+		Choices: []openai.ChatCompletionChoice{{Message: message, FinishReason: finish}},
+	}
+	if scripted.Usage != nil {
+		response.Usage = *scripted.Usage
+	}
+	return response, nil
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// streamAccumulator reassembles streamed chat completion chunks into a regular response.
+type streamAccumulator struct {
+	response  openai.ChatCompletionResponse
+	content   strings.Builder
+	toolCalls []openai.ToolCall
+	finish    openai.FinishReason
+}
+
+func (a *streamAccumulator) add(chunk openai.ChatCompletionStreamResponse) {
+	a.response.ID = chunk.ID
+	a.response.Object = chunk.Object
+	a.response.Created = chunk.Created
+	a.response.Model = chunk.Model
+	a.response.SystemFingerprint = chunk.SystemFingerprint
+	if chunk.Usage != nil {
+		a.response.Usage = *chunk.Usage
+	}
+
+	if len(chunk.Choices) == 0 {
+		return
+	}
+
+	choice := chunk.Choices[0]
+	a.content.WriteString(choice.Delta.Content)
+	for _, delta := range choice.Delta.ToolCalls {
+		a.addToolCallDelta(delta)
+	}
+	if choice.FinishReason != "" {
+		a.finish = choice.FinishReason
+	}
+}
+
+func (a *streamAccumulator) addToolCallDelta(delta openai.ToolCall) {
+	index := len(a.toolCalls) - 1
+	if delta.Index != nil {
+		index = *delta.Index
+	} else if delta.ID != "" {
+		index = len(a.toolCalls)
+	}
+	if index < 0 {
+		index = 0
+	}
+
+	for len(a.toolCalls) <= index {
+		a.toolCalls = append(a.toolCalls, openai.ToolCall{Type: openai.ToolTypeFunction})
+	}
+
+	call := &a.toolCalls[index]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Type != "" {
+		call.Type = delta.Type
+	}
+	if delta.Function.Name != "" && call.Function.Name != delta.Function.Name {
+		call.Function.Name += delta.Function.Name
+	}
+	call.Function.Arguments += delta.Function.Arguments
+}
+
+func (a *streamAccumulator) result() openai.ChatCompletionResponse {
+	response := a.response
+	response.Choices = []openai.ChatCompletionChoice{
+		{
+			Index: 0,
+			Message: openai.ChatCompletionMessage{
+				Role:      openai.ChatMessageRoleAssistant,
+				Content:   a.content.String(),
+				ToolCalls: a.toolCalls,
+			},
+			FinishReason: a.finish,
+		},
+	}
+	return response
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func toolCallDelta(index *int, id, name, arguments string) openai.ToolCall {
+	return openai.ToolCall{Index: index, ID: id, Function: openai.FunctionCall{Name: name, Arguments: arguments}}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func functionCall(id, name, arguments string) openai.ToolCall {
+	return openai.ToolCall{ID: id, Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: name, Arguments: arguments}}
+}
+
+func TestAddToolCallDelta(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas []openai.ToolCall
+		want   []openai.ToolCall
+	}{
+		{
+			name: "indexed parallel calls",
+			deltas: []openai.ToolCall{
+				toolCallDelta(intPtr(0), "call_a", "read", ""),
+				toolCallDelta(intPtr(1), "call_b", "search", `{"pattern"`),
+				toolCallDelta(intPtr(0), "", "", `{"path":`),
+				toolCallDelta(intPtr(1), "", "", `: "x"}`),
+				toolCallDelta(intPtr(0), "", "", ` "a.go"}`),
+			},
+			want: []openai.ToolCall{
+				functionCall("call_a", "read", `{"path": "a.go"}`),
+				functionCall("call_b", "search", `{"pattern": "x"}`),
+			},
+		},
+		{
+			name: "without index a new id starts a new call",
+			deltas: []openai.ToolCall{
+				toolCallDelta(nil, "call_a", "read", `{"path":`),
+				toolCallDelta(nil, "", "", ` "a.go"}`),
+				toolCallDelta(nil, "call_b", "read", `{"path": "b.go"}`),
+			},
+			want: []openai.ToolCall{
+				functionCall("call_a", "read", `{"path": "a.go"}`),
+				functionCall("call_b", "read", `{"path": "b.go"}`),
+			},
+		},
+		{
+			name: "repeated name is not duplicated",
+			deltas: []openai.ToolCall{
+				toolCallDelta(intPtr(0), "call_a", "read", `{"path":`),
+				toolCallDelta(intPtr(0), "", "read", ` "a.go"}`),
+			},
+			want: []openai.ToolCall{functionCall("call_a", "read", `{"path": "a.go"}`)},
+		},
+		{
+			name: "name split across chunks",
+			deltas: []openai.ToolCall{
+				toolCallDelta(intPtr(0), "call_a", "get_file", ""),
+				toolCallDelta(intPtr(0), "", "_contents", `{}`),
+			},
+			want: []openai.ToolCall{functionCall("call_a", "get_file_contents", `{}`)},
+		},
+		{
+			name:   "continuation before any call",
+			deltas: []openai.ToolCall{toolCallDelta(nil, "", "read", `{}`)},
+			want:   []openai.ToolCall{functionCall("", "read", `{}`)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a streamAccumulator
+			for _, delta := range tt.deltas {
+				a.addToolCallDelta(delta)
+			}
+			if !reflect.DeepEqual(a.toolCalls, tt.want) {
+				t.Errorf("tool calls = %+v, want %+v", a.toolCalls, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamAccumulatorResult(t *testing.T) {
+	var a streamAccumulator
+	chunks := []openai.ChatCompletionStreamResponse{
+		{ID: "resp", Model: "m", Choices: []openai.ChatCompletionStreamChoice{{Delta: openai.ChatCompletionStreamChoiceDelta{Content: "Reading "}}}},
+		{ID: "resp", Model: "m", Choices: []openai.ChatCompletionStreamChoice{{Delta: openai.ChatCompletionStreamChoiceDelta{
+			Content:   "the file",
+			ToolCalls: []openai.ToolCall{toolCallDelta(intPtr(0), "call_a", "read", `{}`)},
+		}}}},
+		{ID: "resp", Model: "m", Choices: []openai.ChatCompletionStreamChoice{{FinishReason: openai.FinishReasonToolCalls}}},
+		{ID: "resp", Model: "m", Usage: &openai.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}},
+	}
+	for _, chunk := range chunks {
+		a.add(chunk)
+	}
+
+	response := a.result()
+	if response.ID != "resp" || response.Model != "m" || response.Usage.TotalTokens != 15 {
+		t.Errorf("response = %+v", response)
+	}
+	if len(response.Choices) != 1 {
+		t.Fatalf("got %d choices, want 1", len(response.Choices))
+	}
+	choice := response.Choices[0]
+	want := openai.ChatCompletionMessage{
+		Role:      openai.ChatMessageRoleAssistant,
+		Content:   "Reading the file",
+		ToolCalls: []openai.ToolCall{functionCall("call_a", "read", `{}`)},
+	}
+	if !reflect.DeepEqual(choice.Message, want) || choice.FinishReason != openai.FinishReasonToolCalls {
+		t.Errorf("choice = %+v, want message %+v finishing with tool calls", choice, want)
+	}
+}
@@ -0,0 +1,163 @@
+package bitbucket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client talks to the Bitbucket Server (Data Center) REST API.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+type Repository struct {
+	ProjectKey string
+	Slug       string
+}
+
+type PullRequestInput struct {
+	Title        string
+	Description  string
+	SourceBranch string
+	TargetBranch string
+}
+
+type PullRequest struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	State string `json:"state"`
+	Links struct {
+		Self []struct {
+			Href string `json:"href"`
+		} `json:"self"`
+	} `json:"links"`
+}
+
+func (p *PullRequest) URL() string {
+	if len(p.Links.Self) == 0 {
+		return ""
+	}
+	return p.Links.Self[0].Href
+}
+
+func NewClient(host, token string) *Client {
+	baseURL := strings.TrimRight(host, "/")
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "https://" + baseURL
+	}
+	return &Client{
+		baseURL:    baseURL,
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *Client) CreatePullRequest(ctx context.Context, repo Repository, input PullRequestInput) (*PullRequest, error) {
+	ref := func(branch string) map[string]interface{} {
+		return map[string]interface{}{
+			"id": "refs/heads/" + branch,
+			"repository": map[string]interface{}{
+				"slug":    repo.Slug,
+				"project": map[string]interface{}{"key": repo.ProjectKey},
+			},
+		}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"title":       input.Title,
+		"description": input.Description,
+		"state":       "OPEN",
+		"open":        true,
+		"closed":      false,
+		"locked":      false,
+		"fromRef":     ref(input.SourceBranch),
+		"toRef":       ref(input.TargetBranch),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s/pull-requests",
+		c.baseURL, url.PathEscape(repo.ProjectKey), url.PathEscape(repo.Slug))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bitbucket returned %s: %s", resp.Status, errorMessage(respBody))
+	}
+
+	var pr PullRequest
+	if err := json.Unmarshal(respBody, &pr); err != nil {
+		return nil, fmt.Errorf("error parsing bitbucket response: %w", err)
+	}
+	return &pr, nil
+}
+
+// ParseRepository extracts the project key and repository slug from a Bitbucket Server clone URL, e.g.
+// https://host/scm/PROJ/repo.git, ssh://git@host:7999/proj/repo.git or git@host:proj/repo.git.
+func ParseRepository(remoteURL string) (Repository, error) {
+	path := remoteURL
+	if u, err := url.Parse(remoteURL); err == nil && u.Scheme != "" {
+		path = u.Path
+	} else if idx := strings.Index(remoteURL, ":"); idx >= 0 {
+		path = remoteURL[idx+1:]
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 {
+		return Repository{}, fmt.Errorf("cannot determine bitbucket project and repository from %q", remoteURL)
+	}
+
+	project := parts[len(parts)-2]
+	slug := strings.TrimSuffix(parts[len(parts)-1], ".git")
+	if project == "" || slug == "" {
+		return Repository{}, fmt.Errorf("cannot determine bitbucket project and repository from %q", remoteURL)
+	}
+	// Keys of personal repositories are the owner's user name prefixed with ~, which is not upper-cased.
+	if !strings.HasPrefix(project, "~") {
+		project = strings.ToUpper(project)
+	}
+	return Repository{ProjectKey: project, Slug: slug}, nil
+}
+
+func errorMessage(body []byte) string {
+	var payload struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || len(payload.Errors) == 0 {
+		return strings.TrimSpace(string(body))
+	}
+
+	messages := make([]string, 0, len(payload.Errors))
+	for _, e := range payload.Errors {
+		messages = append(messages, e.Message)
+	}
+	return strings.Join(messages, "; ")
+}
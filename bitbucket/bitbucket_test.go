@@ -0,0 +1,130 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreatePullRequest(t *testing.T) {
+	var gotPath, gotAuth string
+	var gotBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotAuth = r.Header.Get("Authorization")
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("error decoding request body: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 42, "title": "Fix things", "state": "OPEN", "links": {"self": [{"href": "https://bb/pr/42"}]}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", "secret-token")
+	pr, err := client.CreatePullRequest(context.Background(), Repository{ProjectKey: "~jdoe", Slug: "repo"}, PullRequestInput{
+		Title:        "Fix things",
+		Description:  "Details",
+		SourceBranch: "dwight/fix",
+		TargetBranch: "main",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pr.ID != 42 || pr.URL() != "https://bb/pr/42" {
+		t.Errorf("pull request = %+v, want id 42 with its link", pr)
+	}
+	if want := "/rest/api/1.0/projects/~jdoe/repos/repo/pull-requests"; gotPath != want {
+		t.Errorf("path = %s, want %s", gotPath, want)
+	}
+	if gotAuth != "Bearer secret-token" {
+		t.Errorf("Authorization = %q, want bearer token", gotAuth)
+	}
+	if gotBody["title"] != "Fix things" || gotBody["description"] != "Details" {
+		t.Errorf("body title/description = %v/%v", gotBody["title"], gotBody["description"])
+	}
+	fromRef, _ := gotBody["fromRef"].(map[string]interface{})
+	toRef, _ := gotBody["toRef"].(map[string]interface{})
+	if fromRef["id"] != "refs/heads/dwight/fix" || toRef["id"] != "refs/heads/main" {
+		t.Errorf("refs = %v -> %v", fromRef["id"], toRef["id"])
+	}
+}
+
+func TestCreatePullRequestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "bitbucket errors",
+			status:  http.StatusConflict,
+			body:    `{"errors": [{"message": "Only one pull request may be open"}, {"message": "second"}]}`,
+			wantErr: "409 Conflict: Only one pull request may be open; second",
+		},
+		{
+			name:    "unauthorized plain body",
+			status:  http.StatusUnauthorized,
+			body:    "Authentication failed\n",
+			wantErr: "401 Unauthorized: Authentication failed",
+		},
+		{
+			name:    "invalid json",
+			status:  http.StatusCreated,
+			body:    "<html>",
+			wantErr: "error parsing bitbucket response",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := NewClient(server.URL, "token").CreatePullRequest(context.Background(), Repository{ProjectKey: "PROJ", Slug: "repo"}, PullRequestInput{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewClientDefaultsToHTTPS(t *testing.T) {
+	if got := NewClient("bitbucket.example.com/", "").baseURL; got != "https://bitbucket.example.com" {
+		t.Errorf("baseURL = %s", got)
+	}
+}
+
+func TestParseRepository(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    Repository
+		wantErr bool
+	}{
+		{url: "https://host/scm/proj/repo.git", want: Repository{ProjectKey: "PROJ", Slug: "repo"}},
+		{url: "ssh://git@host:7999/proj/repo.git", want: Repository{ProjectKey: "PROJ", Slug: "repo"}},
+		{url: "git@host:proj/repo.git", want: Repository{ProjectKey: "PROJ", Slug: "repo"}},
+		{url: "https://host/scm/~jdoe/repo.git", want: Repository{ProjectKey: "~jdoe", Slug: "repo"}},
+		{url: "ssh://git@host:7999/~JDoe/repo.git", want: Repository{ProjectKey: "~JDoe", Slug: "repo"}},
+		{url: "repo", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRepository(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRepository(%q) error = %v", tt.url, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRepository(%q) = %+v, want %+v", tt.url, got, tt.want)
+		}
+	}
+}
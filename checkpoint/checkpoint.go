@@ -0,0 +1,438 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// refPrefix keeps checkpoints out of the branch and tag namespaces so they never show up in normal git workflows.
+const refPrefix = "refs/dwight/checkpoints/"
+
+const idLayout = "20060102-150405.000"
+
+// excluded lists paths that belong to dwight itself, such as sessions and the project policy. They are
+// neither snapshotted nor touched by Restore.
+var excluded = []string{".dwight/"}
+
+type Checkpoint struct {
+	ID      string
+	Hash    plumbing.Hash
+	Message string
+	Created time.Time
+}
+
+// Create snapshots every non-ignored file of the working tree, including untracked ones,
+// into a commit stored under a hidden ref. Neither the index nor HEAD are touched.
+func Create(message string) (*Checkpoint, error) {
+	repo, root, err := openRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	matcher, err := ignoreMatcher(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	treeHash, err := writeTree(repo, root, nil, matcher)
+	if err != nil {
+		return nil, fmt.Errorf("error snapshotting working tree: %w", err)
+	}
+
+	now := time.Now()
+	signature := object.Signature{Name: "dwight", Email: "dwight@localhost", When: now}
+	commit := &object.Commit{
+		Author:    signature,
+		Committer: signature,
+		Message:   message,
+		TreeHash:  treeHash,
+	}
+	if head, err := repo.Head(); err == nil {
+		commit.ParentHashes = []plumbing.Hash{head.Hash()}
+	}
+
+	obj := repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return nil, err
+	}
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uniqueID(repo, now)
+	if err != nil {
+		return nil, err
+	}
+	ref := plumbing.NewHashReference(plumbing.ReferenceName(refPrefix+id), hash)
+	if err := repo.Storer.SetReference(ref); err != nil {
+		return nil, err
+	}
+
+	return &Checkpoint{ID: id, Hash: hash, Message: message, Created: now}, nil
+}
+
+// uniqueID derives a checkpoint id from its creation time, adding a counter if the id is already taken.
+func uniqueID(repo *git.Repository, now time.Time) (string, error) {
+	base := now.Format(idLayout)
+	id := base
+	for n := 2; ; n++ {
+		_, err := repo.Storer.Reference(plumbing.ReferenceName(refPrefix + id))
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return id, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// List returns all checkpoints, newest first.
+func List() ([]Checkpoint, error) {
+	repo, _, err := openRepository()
+	if err != nil {
+		return nil, err
+	}
+	return listCheckpoints(repo)
+}
+
+// Restore brings the working tree back to the state captured by the checkpoint with the given id,
+// or by the latest checkpoint if id is empty. Files created after the checkpoint are removed;
+// ignored files are left alone.
+func Restore(id string) (*Checkpoint, error) {
+	repo, root, err := openRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints, err := listCheckpoints(repo)
+	if err != nil {
+		return nil, err
+	}
+	if len(checkpoints) == 0 {
+		return nil, errors.New("no checkpoints found")
+	}
+
+	target := checkpoints[0]
+	if id != "" {
+		found := false
+		for _, cp := range checkpoints {
+			if cp.ID == id {
+				target = cp
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("checkpoint %s not found", id)
+		}
+	}
+
+	commit, err := repo.CommitObject(target.Hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	matcher, err := ignoreMatcher(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[string]bool)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		// Checkpoints made by older versions may contain dwight's own files.
+		if isExcluded(f.Name) {
+			return nil
+		}
+		snapshot[f.Name] = true
+		return restoreFile(root, f)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error restoring files: %w", err)
+	}
+
+	err = walkWorktree(root, matcher, func(rel string) error {
+		if snapshot[rel] {
+			return nil
+		}
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removeEmptyParents(root, filepath.Dir(path))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error removing files created after checkpoint: %w", err)
+	}
+
+	return &target, nil
+}
+
+func openRepository() (*git.Repository, string, error) {
+	repo, err := git.PlainOpenWithOptions(".", &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, "", fmt.Errorf("error opening git repository: %w", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, "", err
+	}
+	return repo, wt.Filesystem.Root(), nil
+}
+
+func ignoreMatcher(repo *git.Repository) (gitignore.Matcher, error) {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	patterns, err := gitignore.ReadPatterns(wt.Filesystem, nil)
+	if err != nil {
+		return nil, err
+	}
+	patterns = append(patterns, wt.Excludes...)
+	for _, p := range excluded {
+		patterns = append(patterns, gitignore.ParsePattern(p, nil))
+	}
+	return gitignore.NewMatcher(patterns), nil
+}
+
+func isExcluded(path string) bool {
+	for _, p := range excluded {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func listCheckpoints(repo *git.Repository) ([]Checkpoint, error) {
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoints []Checkpoint
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().String()
+		if !strings.HasPrefix(name, refPrefix) {
+			return nil
+		}
+		commit, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+		checkpoints = append(checkpoints, Checkpoint{
+			ID:      strings.TrimPrefix(name, refPrefix),
+			Hash:    ref.Hash(),
+			Message: commit.Message,
+			Created: commit.Committer.When,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit times only have second precision, so checkpoints created within the same second are ordered by
+	// their millisecond ids.
+	sort.SliceStable(checkpoints, func(i, j int) bool {
+		if !checkpoints[i].Created.Equal(checkpoints[j].Created) {
+			return checkpoints[i].Created.After(checkpoints[j].Created)
+		}
+		return idAfter(checkpoints[i].ID, checkpoints[j].ID)
+	})
+	return checkpoints, nil
+}
+
+// idAfter reports whether checkpoint id a was created after b. Ids share the idLayout prefix and may have a
+// "-N" counter from uniqueID, which has to be compared numerically.
+func idAfter(a, b string) bool {
+	baseA, counterA := splitID(a)
+	baseB, counterB := splitID(b)
+	if baseA != baseB {
+		return baseA > baseB
+	}
+	return counterA > counterB
+}
+
+func splitID(id string) (string, int) {
+	if len(id) <= len(idLayout) {
+		return id, 1
+	}
+	counter, err := strconv.Atoi(strings.TrimPrefix(id[len(idLayout):], "-"))
+	if err != nil {
+		return id, 1
+	}
+	return id[:len(idLayout)], counter
+}
+
+func writeTree(repo *git.Repository, root string, dir []string, matcher gitignore.Matcher) (plumbing.Hash, error) {
+	entries, err := os.ReadDir(filepath.Join(append([]string{root}, dir...)...))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	tree := &object.Tree{}
+	for _, entry := range entries {
+		if len(dir) == 0 && entry.Name() == ".git" {
+			continue
+		}
+		rel := append(append([]string{}, dir...), entry.Name())
+		if matcher.Match(rel, entry.IsDir()) {
+			continue
+		}
+
+		path := filepath.Join(append([]string{root}, rel...)...)
+		switch {
+		case entry.IsDir():
+			hash, err := writeTree(repo, root, rel, matcher)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			if hash == plumbing.ZeroHash {
+				continue
+			}
+			tree.Entries = append(tree.Entries, object.TreeEntry{Name: entry.Name(), Mode: filemode.Dir, Hash: hash})
+		case entry.Type()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			hash, err := writeBlob(repo, strings.NewReader(target))
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			tree.Entries = append(tree.Entries, object.TreeEntry{Name: entry.Name(), Mode: filemode.Symlink, Hash: hash})
+		case entry.Type().IsRegular():
+			info, err := entry.Info()
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			hash, err := writeBlob(repo, file)
+			file.Close()
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			mode := filemode.Regular
+			if info.Mode()&0111 != 0 {
+				mode = filemode.Executable
+			}
+			tree.Entries = append(tree.Entries, object.TreeEntry{Name: entry.Name(), Mode: mode, Hash: hash})
+		}
+	}
+
+	if len(tree.Entries) == 0 && len(dir) > 0 {
+		return plumbing.ZeroHash, nil
+	}
+
+	sort.Sort(object.TreeEntrySorter(tree.Entries))
+	obj := repo.Storer.NewEncodedObject()
+	if err := tree.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return repo.Storer.SetEncodedObject(obj)
+}
+
+func writeBlob(repo *git.Repository, r io.Reader) (plumbing.Hash, error) {
+	obj := repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return repo.Storer.SetEncodedObject(obj)
+}
+
+func restoreFile(root string, f *object.File) error {
+	path := filepath.Join(root, filepath.FromSlash(f.Name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	content, err := f.Contents()
+	if err != nil {
+		return err
+	}
+
+	if f.Mode == filemode.Symlink {
+		_ = os.Remove(path)
+		return os.Symlink(content, path)
+	}
+
+	perm := os.FileMode(0644)
+	if f.Mode == filemode.Executable {
+		perm = 0755
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		_ = os.Remove(path)
+	}
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		return err
+	}
+	return os.Chmod(path, perm)
+}
+
+func walkWorktree(root string, matcher gitignore.Matcher, fn func(rel string) error) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() && rel == ".git" {
+			return filepath.SkipDir
+		}
+		if matcher.Match(strings.Split(rel, "/"), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		return fn(rel)
+	})
+}
+
+func removeEmptyParents(root, dir string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
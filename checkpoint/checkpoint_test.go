@@ -0,0 +1,141 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func setupRepository(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	writeFile(t, "a.txt", "original")
+	writeFile(t, ".dwight/sessions/s.json", "session v1")
+	return dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRestoreLeavesDwightStateAlone(t *testing.T) {
+	setupRepository(t)
+
+	cp, err := Create("before task")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, "a.txt", "changed")
+	writeFile(t, "new.txt", "created")
+	writeFile(t, ".dwight/sessions/s.json", "session v2")
+	writeFile(t, ".dwight/policy.yaml", "commands: []")
+
+	if _, err := Restore(cp.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, "a.txt"); got != "original" {
+		t.Errorf("a.txt = %q, want original content", got)
+	}
+	if _, err := os.Stat("new.txt"); !os.IsNotExist(err) {
+		t.Errorf("new.txt was not removed: %v", err)
+	}
+	if got := readFile(t, ".dwight/sessions/s.json"); got != "session v2" {
+		t.Errorf("session file = %q, want it untouched", got)
+	}
+	if got := readFile(t, ".dwight/policy.yaml"); got != "commands: []" {
+		t.Errorf("policy file = %q, want it untouched", got)
+	}
+}
+
+func TestCreateUniqueIDs(t *testing.T) {
+	setupRepository(t)
+
+	seen := make(map[string]bool)
+	var created []string
+	for i := 0; i < 3; i++ {
+		cp, err := Create("checkpoint")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[cp.ID] {
+			t.Fatalf("checkpoint id %s was reused", cp.ID)
+		}
+		seen[cp.ID] = true
+		created = append(created, cp.ID)
+	}
+
+	checkpoints, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoints) != 3 {
+		t.Fatalf("List() returned %d checkpoints, want 3", len(checkpoints))
+	}
+	for i, cp := range checkpoints {
+		if want := created[len(created)-1-i]; cp.ID != want {
+			t.Errorf("List()[%d] = %s, want %s (newest first)", i, cp.ID, want)
+		}
+	}
+}
+
+func TestRestoreLatestWithinSameSecond(t *testing.T) {
+	setupRepository(t)
+
+	for _, content := range []string{"first", "second", "third"} {
+		writeFile(t, "a.txt", content)
+		if _, err := Create(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, "a.txt", "changed")
+
+	cp, err := Restore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Message != "third" || readFile(t, "a.txt") != "third" {
+		t.Errorf("Restore(\"\") restored %q with a.txt = %q, want the latest checkpoint", cp.Message, readFile(t, "a.txt"))
+	}
+}
+
+func TestIDAfter(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "20240102-150405.120", b: "20240102-150405.119", want: true},
+		{a: "20240102-150405.119", b: "20240102-150405.120", want: false},
+		{a: "20240102-150405.120-2", b: "20240102-150405.120", want: true},
+		{a: "20240102-150405.120-10", b: "20240102-150405.120-9", want: true},
+		{a: "20240102-150405.120-9", b: "20240102-150405.120-10", want: false},
+		{a: "20240102-150405.120-9", b: "20240102-150405.121", want: false},
+	}
+	for _, tt := range tests {
+		if got := idAfter(tt.a, tt.b); got != tt.want {
+			t.Errorf("idAfter(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rofleksey/dwight/checkpoint"
+	"github.com/spf13/cobra"
+)
+
+func NewCheckpointsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "checkpoints",
+		Short: "List working tree checkpoints created before tasks",
+		Run: func(_ *cobra.Command, _ []string) {
+			checkpoints, err := checkpoint.List()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error listing checkpoints: %v\n", err)
+				os.Exit(1)
+			}
+
+			if len(checkpoints) == 0 {
+				fmt.Println("No checkpoints")
+				return
+			}
+
+			for _, cp := range checkpoints {
+				fmt.Printf("%s  %s  %s\n", cp.ID, cp.Created.Format("2006-01-02 15:04:05"), cp.Message)
+			}
+		},
+	}
+}
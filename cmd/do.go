@@ -6,14 +6,21 @@ import (
 
 	"github.com/rofleksey/dwight/api"
 	"github.com/rofleksey/dwight/config"
+	"github.com/rofleksey/dwight/event"
+	"github.com/rofleksey/dwight/policy"
 	"github.com/rofleksey/dwight/task"
 	"github.com/rofleksey/dwight/util"
 	"github.com/spf13/cobra"
 )
 
 type DoCmd struct {
-	query string
-	yes   bool
+	query  string
+	yes    bool
+	pr     bool
+	output string
+	report string
+
+	nonInteractive nonInteractiveFlags
 }
 
 func NewDoCmd() *cobra.Command {
@@ -25,11 +32,17 @@ func NewDoCmd() *cobra.Command {
 	}
 	cmd.Flags().StringVarP(&doCmd.query, "query", "q", "", "Task description")
 	cmd.Flags().BoolVarP(&doCmd.yes, "yes", "y", false, "Automatically answer Yes to all confirmations (except ask_question)")
+	cmd.Flags().BoolVar(&doCmd.pr, "pr", false, "Commit changes to a new branch and open a Bitbucket pull request after the task completes")
+	cmd.Flags().StringVar(&doCmd.output, "output", "text", outputFlagUsage)
+	cmd.Flags().StringVar(&doCmd.report, "report", "", reportFlagUsage)
 	cmd.MarkFlagRequired("query")
+	doCmd.nonInteractive.register(cmd)
 	return cmd
 }
 
 func (d *DoCmd) run(_ *cobra.Command, _ []string) {
+	events := newEventSink(d.output)
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
@@ -38,12 +51,25 @@ func (d *DoCmd) run(_ *cobra.Command, _ []string) {
 
 	util.SetAutoConfirm(d.yes)
 
-	client := api.NewOpenAIClient(cfg)
-	executor := task.NewExecutor(client, cfg)
+	client, err := api.NewProvider(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating provider: %v\n", err)
+		os.Exit(1)
+	}
 
-	fmt.Println("Executing task...")
-	if err := executor.Execute(d.query); err != nil {
-		fmt.Fprintf(os.Stderr, "Error executing task: %v\n", err)
+	pol, err := policy.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading policy: %v\n", err)
 		os.Exit(1)
 	}
+	executor := task.NewExecutor(client, cfg, pol, events)
+	d.nonInteractive.apply(executor)
+
+	events.Emit(event.Info("Executing task..."))
+	err = executor.Execute(d.query)
+	finishTask(executor, err, d.report)
+
+	if d.pr {
+		createPullRequest(executor, events)
+	}
 }
@@ -6,6 +6,8 @@ import (
 
 	"github.com/rofleksey/dwight/api"
 	"github.com/rofleksey/dwight/config"
+	"github.com/rofleksey/dwight/event"
+	"github.com/rofleksey/dwight/policy"
 	"github.com/rofleksey/dwight/task"
 	"github.com/rofleksey/dwight/util"
 	"github.com/spf13/cobra"
@@ -14,6 +16,11 @@ import (
 type FileCmd struct {
 	inputFile string
 	yes       bool
+	pr        bool
+	output    string
+	report    string
+
+	nonInteractive nonInteractiveFlags
 }
 
 func NewFileCmd() *cobra.Command {
@@ -25,7 +32,11 @@ func NewFileCmd() *cobra.Command {
 	}
 	cmd.Flags().StringVarP(&fileCmd.inputFile, "input", "i", "", "Task description file")
 	cmd.Flags().BoolVarP(&fileCmd.yes, "yes", "y", false, "Automatically answer Yes to all confirmations (except ask_question)")
+	cmd.Flags().BoolVar(&fileCmd.pr, "pr", false, "Commit changes to a new branch and open a Bitbucket pull request after the task completes")
+	cmd.Flags().StringVar(&fileCmd.output, "output", "text", outputFlagUsage)
+	cmd.Flags().StringVar(&fileCmd.report, "report", "", reportFlagUsage)
 	cmd.MarkFlagRequired("input")
+	fileCmd.nonInteractive.register(cmd)
 	return cmd
 }
 
@@ -36,6 +47,8 @@ func (d *FileCmd) run(_ *cobra.Command, _ []string) {
 		os.Exit(1)
 	}
 
+	events := newEventSink(d.output)
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
@@ -44,12 +57,25 @@ func (d *FileCmd) run(_ *cobra.Command, _ []string) {
 
 	util.SetAutoConfirm(d.yes)
 
-	client := api.NewOpenAIClient(cfg)
-	executor := task.NewExecutor(client, cfg)
+	client, err := api.NewProvider(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating provider: %v\n", err)
+		os.Exit(1)
+	}
 
-	fmt.Println("Executing task...")
-	if err := executor.Execute(string(taskContent)); err != nil {
-		fmt.Fprintf(os.Stderr, "Error executing task: %v\n", err)
+	pol, err := policy.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading policy: %v\n", err)
 		os.Exit(1)
 	}
+	executor := task.NewExecutor(client, cfg, pol, events)
+	d.nonInteractive.apply(executor)
+
+	events.Emit(event.Info("Executing task..."))
+	err = executor.Execute(string(taskContent))
+	finishTask(executor, err, d.report)
+
+	if d.pr {
+		createPullRequest(executor, events)
+	}
 }
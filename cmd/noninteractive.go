@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rofleksey/dwight/task"
+	"github.com/spf13/cobra"
+)
+
+type nonInteractiveFlags struct {
+	enabled    bool
+	answers    string
+	unanswered string
+}
+
+func (f *nonInteractiveFlags) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.enabled, "non-interactive", false, "Never wait for input: answer questions from --answers and fail on confirmations the policy or --yes does not approve")
+	cmd.Flags().StringVar(&f.answers, "answers", "", "YAML file with question regexes and answers for --non-interactive")
+	cmd.Flags().StringVar(&f.unanswered, "unanswered", task.UnansweredAssume, "What to do with questions without a scripted answer in --non-interactive mode: assume or fail")
+}
+
+func (f *nonInteractiveFlags) apply(executor *task.Executor) {
+	if !f.enabled {
+		return
+	}
+	answers, err := task.LoadAnswers(f.answers, f.unanswered)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading answers: %v\n", err)
+		os.Exit(task.ExitFailed)
+	}
+	executor.SetNonInteractive(answers)
+}
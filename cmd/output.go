@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rofleksey/dwight/event"
+)
+
+const outputFlagUsage = "Output format: text, or json for a stream of newline-delimited JSON events"
+
+// newEventSink returns the renderer for the --output flag.
+func newEventSink(format string) event.Sink {
+	sink, err := event.New(format, os.Stdout, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return sink
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rofleksey/dwight/event"
+	"github.com/rofleksey/dwight/task"
+)
+
+func createPullRequest(executor *task.Executor, events event.Sink) {
+	if !executor.Completed() {
+		fmt.Fprintln(os.Stderr, "Task was not completed, skipping pull request creation")
+		os.Exit(1)
+	}
+
+	pr, err := executor.CreatePullRequest()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating pull request: %v\n", err)
+		os.Exit(1)
+	}
+
+	events.Emit(event.Info("Created pull request #%d: %s", pr.ID, pr.URL()))
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rofleksey/dwight/task"
+)
+
+const reportFlagUsage = "Write the final task report as JSON to this file"
+
+// finishTask reports the outcome of a task run and exits with its exit code unless the task completed.
+func finishTask(executor *task.Executor, err error, reportPath string) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error executing task: %v\n", err)
+	}
+	if reportPath != "" {
+		if reportErr := executor.WriteReport(reportPath); reportErr != nil {
+			fmt.Fprintf(os.Stderr, "Error writing report: %v\n", reportErr)
+		}
+	}
+
+	code := executor.ExitCode()
+	if err != nil && code == task.ExitCompleted {
+		code = task.ExitFailed
+	}
+	if code != task.ExitCompleted {
+		os.Exit(code)
+	}
+}
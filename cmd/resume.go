@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rofleksey/dwight/api"
+	"github.com/rofleksey/dwight/config"
+	"github.com/rofleksey/dwight/event"
+	"github.com/rofleksey/dwight/policy"
+	"github.com/rofleksey/dwight/session"
+	"github.com/rofleksey/dwight/task"
+	"github.com/rofleksey/dwight/util"
+	"github.com/spf13/cobra"
+)
+
+type ResumeCmd struct {
+	query  string
+	yes    bool
+	output string
+	report string
+
+	nonInteractive nonInteractiveFlags
+}
+
+func NewResumeCmd() *cobra.Command {
+	resumeCmd := &ResumeCmd{}
+	cmd := &cobra.Command{
+		Use:   "resume <session>",
+		Short: "Resume an interrupted task session",
+		Args:  cobra.ExactArgs(1),
+		Run:   resumeCmd.run,
+	}
+	cmd.Flags().StringVarP(&resumeCmd.query, "query", "q", "", "Follow-up message to send to the model")
+	cmd.Flags().BoolVarP(&resumeCmd.yes, "yes", "y", false, "Automatically answer Yes to all confirmations (except ask_question)")
+	cmd.Flags().StringVar(&resumeCmd.output, "output", "text", outputFlagUsage)
+	cmd.Flags().StringVar(&resumeCmd.report, "report", "", reportFlagUsage)
+	resumeCmd.nonInteractive.register(cmd)
+	return cmd
+}
+
+func (r *ResumeCmd) run(_ *cobra.Command, args []string) {
+	sess, err := session.Load(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading session: %v\n", err)
+		os.Exit(1)
+	}
+
+	events := newEventSink(r.output)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	util.SetAutoConfirm(r.yes)
+
+	client, err := api.NewProvider(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating provider: %v\n", err)
+		os.Exit(1)
+	}
+
+	pol, err := policy.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading policy: %v\n", err)
+		os.Exit(1)
+	}
+	executor := task.NewExecutor(client, cfg, pol, events)
+	r.nonInteractive.apply(executor)
+
+	events.Emit(event.Info("Resuming session %s...", sess.ID))
+	err = executor.Resume(sess, r.query)
+	finishTask(executor, err, r.report)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rofleksey/dwight/session"
+	"github.com/spf13/cobra"
+)
+
+const sessionPreviewLen = 200
+
+func NewSessionsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "Manage stored task sessions",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List stored sessions",
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			sessions, err := session.List()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error listing sessions: %v\n", err)
+				os.Exit(1)
+			}
+
+			if len(sessions) == 0 {
+				fmt.Println("No sessions")
+				return
+			}
+
+			for _, s := range sessions {
+				fmt.Printf("%s  %-9s  %s  %s\n", s.ID, s.Status, s.UpdatedAt.Format("2006-01-02 15:04:05"), preview(s.Task, 60))
+			}
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show <session>",
+		Short: "Show the conversation of a session",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			s, err := session.Load(args[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading session: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Session:    %s\n", s.ID)
+			fmt.Printf("Status:     %s\n", s.Status)
+			if s.Error != "" {
+				fmt.Printf("Error:      %s\n", s.Error)
+			}
+			if s.CheckpointID != "" {
+				fmt.Printf("Checkpoint: %s\n", s.CheckpointID)
+			}
+			fmt.Printf("Created:    %s\n", s.CreatedAt.Format("2006-01-02 15:04:05"))
+			fmt.Printf("Updated:    %s\n", s.UpdatedAt.Format("2006-01-02 15:04:05"))
+			fmt.Printf("Task:\n%s\n\n", s.Task)
+
+			for i, msg := range s.Messages {
+				fmt.Printf("\x1b[34m[%d] %s\x1b[0m\n", i, msg.Role)
+				if msg.Content != "" {
+					fmt.Println(preview(msg.Content, sessionPreviewLen))
+				}
+				for _, call := range msg.ToolCalls {
+					fmt.Printf("-> %s(%s)\n", call.Function.Name, preview(call.Function.Arguments, sessionPreviewLen))
+				}
+			}
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "rm <session>...",
+		Short: "Remove sessions",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			for _, id := range args {
+				if err := session.Remove(id); err != nil {
+					fmt.Fprintf(os.Stderr, "Error removing session: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("Removed session %s\n", id)
+			}
+		},
+	})
+
+	return cmd
+}
+
+// preview collapses whitespace and shortens text to maxLen runes, so multi-byte characters are never split.
+func preview(text string, maxLen int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) > maxLen {
+		return string(runes[:maxLen]) + "..."
+	}
+	return string(runes)
+}
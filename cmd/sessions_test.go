@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPreview(t *testing.T) {
+	tests := []struct {
+		text   string
+		maxLen int
+		want   string
+	}{
+		{text: "short", maxLen: 10, want: "short"},
+		{text: "  fix\n\tthe   bug ", maxLen: 20, want: "fix the bug"},
+		{text: "abcdef", maxLen: 3, want: "abc..."},
+		{text: "исправить ошибку", maxLen: 9, want: "исправить..."},
+		{text: "日本語のテキスト", maxLen: 3, want: "日本語..."},
+	}
+	for _, tt := range tests {
+		got := preview(tt.text, tt.maxLen)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("preview(%q, %d) = %q, want %q", tt.text, tt.maxLen, got, tt.want)
+		}
+	}
+}
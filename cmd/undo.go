@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rofleksey/dwight/checkpoint"
+	"github.com/rofleksey/dwight/util"
+	"github.com/spf13/cobra"
+)
+
+type UndoCmd struct {
+	yes bool
+}
+
+func NewUndoCmd() *cobra.Command {
+	undoCmd := &UndoCmd{}
+	cmd := &cobra.Command{
+		Use:   "undo [checkpoint]",
+		Short: "Restore the working tree to a checkpoint (latest by default)",
+		Args:  cobra.MaximumNArgs(1),
+		Run:   undoCmd.run,
+	}
+	cmd.Flags().BoolVarP(&undoCmd.yes, "yes", "y", false, "Do not ask for confirmation")
+	return cmd
+}
+
+func (u *UndoCmd) run(_ *cobra.Command, args []string) {
+	id := ""
+	if len(args) > 0 {
+		id = args[0]
+	}
+
+	util.SetAutoConfirm(u.yes)
+
+	target := "the latest checkpoint"
+	if id != "" {
+		target = "checkpoint " + id
+	}
+	if !util.ConfirmAction(fmt.Sprintf("Restore working tree to %s? Uncommitted changes made since then will be lost", target)) {
+		return
+	}
+
+	cp, err := checkpoint.Restore(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error restoring checkpoint: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Restored checkpoint %s (%s)\n", cp.ID, cp.Message)
+}
@@ -3,18 +3,81 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
 )
 
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+)
+
 type Config struct {
-	BaseURL         string `mapstructure:"base_url" validate:"required"`
-	Token           string `mapstructure:"token" validate:"required"`
-	Model           string `mapstructure:"model" validate:"required"`
-	SnippetMaxLines int    `mapstructure:"snippet_max_lines" validate:"required,min=1"`
-	BitbucketHost   string `mapstructure:"bitbucket_host"`
-	BitbucketToken  string `mapstructure:"bitbucket_token"`
+	Provider            string        `mapstructure:"provider" validate:"oneof=openai anthropic ollama"`
+	BaseURL             string        `mapstructure:"base_url"`
+	Token               string        `mapstructure:"token" validate:"required_if=Provider openai,required_if=Provider anthropic"`
+	Model               string        `mapstructure:"model" validate:"required"`
+	MaxOutputTokens     int           `mapstructure:"max_output_tokens" validate:"min=1"`
+	ContextWindow       int           `mapstructure:"context_window" validate:"min=1000"`
+	CompactAt           float64       `mapstructure:"compact_at" validate:"gt=0,lte=1"`
+	SnippetMaxLines     int           `mapstructure:"snippet_max_lines" validate:"required,min=1"`
+	ReadMaxBytes        int           `mapstructure:"read_max_bytes" validate:"required,min=1"`
+	UseGitignore        bool          `mapstructure:"use_gitignore"`
+	OutlineExtensions   []string      `mapstructure:"outline_extensions"`
+	OutlineDepth        int           `mapstructure:"outline_depth" validate:"required,min=1"`
+	StructureMaxTokens  int           `mapstructure:"structure_max_tokens" validate:"min=0"`
+	BitbucketHost       string        `mapstructure:"bitbucket_host"`
+	BitbucketToken      string        `mapstructure:"bitbucket_token"`
+	CommandTimeout      time.Duration `mapstructure:"command_timeout" validate:"min=0"`
+	CommandMaxOutput    int           `mapstructure:"command_max_output" validate:"min=0"`
+	CommandJail         bool          `mapstructure:"command_jail"`
+	CommandEnvAllowlist []string      `mapstructure:"command_env_allowlist"`
+	CommandSandbox      string        `mapstructure:"command_sandbox" validate:"oneof=none namespace bwrap"`
+	Prices              []Price       `mapstructure:"prices" validate:"dive"`
+	MaxCost             float64       `mapstructure:"max_cost" validate:"min=0"`
+	MaxTokens           int           `mapstructure:"max_tokens" validate:"min=0"`
+	RedactSecrets       bool          `mapstructure:"redact_secrets"`
+	RedactEntropy       bool          `mapstructure:"redact_entropy"`
+	RedactPatterns      []Pattern     `mapstructure:"redact_patterns" validate:"dive"`
+	ProtectedPaths      []string      `mapstructure:"protected_paths"`
+	ReviewMode          string        `mapstructure:"review_mode" validate:"oneof=file hunk"`
+	VerifyCommand       string        `mapstructure:"verify_command"`
+	VerifyMaxAttempts   int           `mapstructure:"verify_max_attempts" validate:"min=1"`
+	MaxTurns            int           `mapstructure:"max_turns" validate:"min=0"`
+	MaxToolCalls        int           `mapstructure:"max_tool_calls" validate:"min=0"`
+	MaxDuration         time.Duration `mapstructure:"max_duration" validate:"min=0"`
+	RepeatLimit         int           `mapstructure:"repeat_limit" validate:"min=0"`
+}
+
+// Pattern is a named regular expression. Redaction patterns with a capture group named "secret"
+// only replace that group.
+type Pattern struct {
+	Name  string `mapstructure:"name" validate:"required"`
+	Regex string `mapstructure:"regex" validate:"required"`
+}
+
+// Price is the cost of a model in currency units per million tokens.
+type Price struct {
+	Model       string  `mapstructure:"model" validate:"required"`
+	Input       float64 `mapstructure:"input" validate:"min=0"`
+	CachedInput float64 `mapstructure:"cached_input" validate:"min=0"`
+	Output      float64 `mapstructure:"output" validate:"min=0"`
+}
+
+// PriceFor returns the configured price of the model, if any.
+func (c *Config) PriceFor(model string) (Price, bool) {
+	for _, price := range c.Prices {
+		if strings.EqualFold(price.Model, model) {
+			return price, true
+		}
+	}
+	return Price{}, false
 }
 
 func LoadConfig() (*Config, error) {
@@ -27,7 +90,25 @@ func LoadConfig() (*Config, error) {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(home)
 
+	viper.SetDefault("provider", ProviderOpenAI)
+	viper.SetDefault("max_output_tokens", 8192)
+	viper.SetDefault("context_window", 128000)
+	viper.SetDefault("compact_at", 0.8)
 	viper.SetDefault("snippet_max_lines", 50)
+	viper.SetDefault("read_max_bytes", 100*1024)
+	viper.SetDefault("use_gitignore", true)
+	viper.SetDefault("outline_depth", 2)
+	viper.SetDefault("command_timeout", 10*time.Minute)
+	viper.SetDefault("command_max_output", 64*1024)
+	viper.SetDefault("command_jail", true)
+	viper.SetDefault("command_sandbox", "none")
+	viper.SetDefault("redact_secrets", true)
+	viper.SetDefault("redact_entropy", true)
+	viper.SetDefault("review_mode", "file")
+	viper.SetDefault("verify_max_attempts", 3)
+	viper.SetDefault("max_turns", 100)
+	viper.SetDefault("max_tool_calls", 300)
+	viper.SetDefault("repeat_limit", 3)
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("error reading config: %w", err)
@@ -42,6 +123,16 @@ func LoadConfig() (*Config, error) {
 	if err := validate.Struct(config); err != nil {
 		return nil, fmt.Errorf("config validation failed: %w", err)
 	}
+	for _, pattern := range config.ProtectedPaths {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, fmt.Errorf("config validation failed: invalid protected path pattern %s", pattern)
+		}
+	}
+	for _, pattern := range config.RedactPatterns {
+		if _, err := regexp.Compile(pattern.Regex); err != nil {
+			return nil, fmt.Errorf("config validation failed: invalid redact pattern %s: %w", pattern.Name, err)
+		}
+	}
 
 	return &config, nil
 }
@@ -0,0 +1,122 @@
+package event
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+type Type string
+
+const (
+	// Message is a progress line; Level tells informational messages from warnings and errors.
+	Message Type = "message"
+	// RequestStarted and RequestFinished surround every model request.
+	RequestStarted  Type = "request_started"
+	RequestFinished Type = "request_finished"
+	// ModelMessage carries a chunk of the model's text as it is streamed.
+	ModelMessage Type = "model_message"
+	// ToolCall is emitted before a tool call requested by the model is handled, ToolResult after it.
+	ToolCall   Type = "tool_call"
+	ToolResult Type = "tool_result"
+	// Diff shows a proposed change of a file as a plain unified diff.
+	Diff Type = "diff"
+	// CommandOutput carries a chunk of stdout or stderr of a running command.
+	CommandOutput Type = "command_output"
+	// ConfirmationNeeded is emitted before the user is asked to confirm or choose, Answer is set if
+	// the answer was given without asking, e.g. with --yes.
+	ConfirmationNeeded Type = "confirmation_needed"
+	// QuestionAsked is emitted before the user is asked a question by the model, Answer is set if the
+	// question was answered without asking, in non-interactive runs.
+	QuestionAsked Type = "question_asked"
+	Usage         Type = "usage"
+	TaskFinished  Type = "task_finished"
+)
+
+const (
+	LevelInfo    = "info"
+	LevelWarning = "warning"
+	LevelError   = "error"
+)
+
+// Event is a single thing that happened during a task. Only the fields relevant to the type are set.
+type Event struct {
+	Type    Type      `json:"type"`
+	Time    time.Time `json:"time"`
+	Session string    `json:"session,omitempty"`
+
+	Level string `json:"level,omitempty"`
+	Text  string `json:"text,omitempty"`
+
+	Tool       string `json:"tool,omitempty"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Arguments  string `json:"arguments,omitempty"`
+
+	Path   string `json:"path,omitempty"`
+	Diff   string `json:"diff,omitempty"`
+	Stream string `json:"stream,omitempty"`
+
+	Prompt  string   `json:"prompt,omitempty"`
+	Choices []string `json:"choices,omitempty"`
+	Answer  string   `json:"answer,omitempty"`
+
+	Duration float64     `json:"duration_seconds,omitempty"`
+	Usage    *UsageStats `json:"usage,omitempty"`
+	Status   string      `json:"status,omitempty"`
+	// Report is the final report of the task, also rendered as Text.
+	Report any    `json:"report,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// UsageStats counts tokens of a single request, or of the whole task in Total.
+type UsageStats struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CachedTokens     int     `json:"cached_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost,omitempty"`
+	CostKnown        bool    `json:"cost_known"`
+}
+
+// Sink receives events. Implementations render them for a particular consumer.
+type Sink interface {
+	Emit(e Event)
+}
+
+func Info(format string, args ...any) Event {
+	return Event{Type: Message, Level: LevelInfo, Text: fmt.Sprintf(format, args...)}
+}
+
+func Warning(format string, args ...any) Event {
+	return Event{Type: Message, Level: LevelWarning, Text: fmt.Sprintf(format, args...)}
+}
+
+func Error(format string, args ...any) Event {
+	return Event{Type: Message, Level: LevelError, Text: fmt.Sprintf(format, args...)}
+}
+
+// New returns the sink for an output format: "text" renders for a terminal, "json" writes NDJSON.
+func New(format string, stdout, stderr io.Writer) (Sink, error) {
+	switch format {
+	case "", "text":
+		return NewTerminal(stdout, stderr), nil
+	case "json":
+		return NewJSON(stdout), nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected text or json", format)
+	}
+}
+
+// Writer returns a writer that emits everything written to it as CommandOutput events of stream.
+func Writer(sink Sink, stream string) io.Writer {
+	return streamWriter{sink: sink, stream: stream}
+}
+
+type streamWriter struct {
+	sink   Sink
+	stream string
+}
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	w.sink.Emit(Event{Type: CommandOutput, Stream: w.stream, Text: string(p)})
+	return len(p), nil
+}
@@ -0,0 +1,28 @@
+package event
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// JSON writes every event as one line of JSON, for CI logs and editor integrations.
+type JSON struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func NewJSON(w io.Writer) *JSON {
+	return &JSON{encoder: json.NewEncoder(w)}
+}
+
+func (j *JSON) Emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_ = j.encoder.Encode(e)
+}
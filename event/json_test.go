@@ -0,0 +1,132 @@
+package event
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJSONEmitsOneObjectPerLine(t *testing.T) {
+	fixed := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	events := []struct {
+		event Event
+		want  map[string]any
+	}{
+		{
+			event: Warning("disk %s", "full"),
+			want:  map[string]any{"type": "message", "level": "warning", "text": "disk full"},
+		},
+		{
+			event: Event{Type: RequestStarted, Time: fixed, Session: "s1"},
+			want:  map[string]any{"type": "request_started", "time": "2024-01-02T15:04:05Z", "session": "s1"},
+		},
+		{
+			event: Event{Type: RequestFinished, Duration: 1.5},
+			want:  map[string]any{"type": "request_finished", "duration_seconds": 1.5},
+		},
+		{
+			event: Event{Type: ModelMessage, Text: "line one\nline two"},
+			want:  map[string]any{"type": "model_message", "text": "line one\nline two"},
+		},
+		{
+			event: Event{Type: ToolCall, Tool: "edit_file", ToolCallID: "call_0", Arguments: `{"file_path":"a.go"}`},
+			want:  map[string]any{"type": "tool_call", "tool": "edit_file", "tool_call_id": "call_0", "arguments": `{"file_path":"a.go"}`},
+		},
+		{
+			event: Event{Type: ToolResult, Tool: "edit_file", ToolCallID: "call_0", Text: "a.go: Updated"},
+			want:  map[string]any{"type": "tool_result", "tool": "edit_file", "tool_call_id": "call_0", "text": "a.go: Updated"},
+		},
+		{
+			event: Event{Type: Diff, Path: "a.go", Diff: "-old\n+new\n"},
+			want:  map[string]any{"type": "diff", "path": "a.go", "diff": "-old\n+new\n"},
+		},
+		{
+			event: Event{Type: CommandOutput, Stream: "stderr", Text: "warning\n"},
+			want:  map[string]any{"type": "command_output", "stream": "stderr", "text": "warning\n"},
+		},
+		{
+			event: Event{Type: ConfirmationNeeded, Prompt: "Apply?", Choices: []string{"yes", "no"}, Answer: "yes"},
+			want:  map[string]any{"type": "confirmation_needed", "prompt": "Apply?", "choices": []any{"yes", "no"}, "answer": "yes"},
+		},
+		{
+			event: Event{Type: QuestionAsked, Prompt: "Which port?"},
+			want:  map[string]any{"type": "question_asked", "prompt": "Which port?"},
+		},
+		{
+			event: Event{Type: Usage, Usage: &UsageStats{PromptTokens: 10, CachedTokens: 4, CompletionTokens: 2, Cost: 0.5, CostKnown: true}},
+			want: map[string]any{"type": "usage", "usage": map[string]any{
+				"prompt_tokens": 10.0, "cached_tokens": 4.0, "completion_tokens": 2.0, "cost": 0.5, "cost_known": true,
+			}},
+		},
+		{
+			event: Event{Type: TaskFinished, Status: "failed", Error: "boom", Report: map[string]int{"exit_code": 1}},
+			want:  map[string]any{"type": "task_finished", "status": "failed", "error": "boom", "report": map[string]any{"exit_code": 1.0}},
+		},
+	}
+
+	var buf bytes.Buffer
+	sink := NewJSON(&buf)
+	for _, e := range events {
+		sink.Emit(e.event)
+	}
+
+	scanner := bufio.NewScanner(&buf)
+	line := 0
+	for ; scanner.Scan(); line++ {
+		if line >= len(events) {
+			t.Fatalf("unexpected line %d: %s", line, scanner.Text())
+		}
+		var got map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+			t.Fatalf("line %d is not a JSON object: %v\n%s", line, err, scanner.Text())
+		}
+
+		want := events[line].want
+		if _, ok := want["time"]; !ok {
+			// Events without a time are stamped when emitted.
+			stamp, _ := got["time"].(string)
+			if _, err := time.Parse(time.RFC3339Nano, stamp); err != nil {
+				t.Errorf("line %d: time = %v, want the emission time", line, got["time"])
+			}
+			delete(got, "time")
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("line %d = %v, want %v", line, got, want)
+		}
+	}
+	if line != len(events) {
+		t.Errorf("got %d lines, want %d", line, len(events))
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := Writer(NewJSON(&buf), "stdout")
+	if _, err := io.WriteString(w, "ok\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got Event
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != CommandOutput || got.Stream != "stdout" || got.Text != "ok\n" {
+		t.Errorf("event = %+v, want stdout command output", got)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for format, want := range map[string]Sink{"": &Terminal{}, "text": &Terminal{}, "json": &JSON{}} {
+		sink, err := New(format, io.Discard, io.Discard)
+		if err != nil || reflect.TypeOf(sink) != reflect.TypeOf(want) {
+			t.Errorf("New(%q) = %T, %v, want %T", format, sink, err, want)
+		}
+	}
+	if _, err := New("xml", io.Discard, io.Discard); err == nil {
+		t.Error("New(xml) succeeded, want an unknown format error")
+	}
+}
@@ -0,0 +1,125 @@
+package event
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rofleksey/dwight/util"
+)
+
+// Terminal renders events for a person watching the terminal: a spinner while waiting for the model,
+// streamed model messages, colored diffs and inline prompts.
+type Terminal struct {
+	stdout io.Writer
+	stderr io.Writer
+
+	requestStart   time.Time
+	spinnerDone    chan struct{}
+	spinnerStopped chan struct{}
+	printedHeader  bool
+}
+
+func NewTerminal(stdout, stderr io.Writer) *Terminal {
+	return &Terminal{stdout: stdout, stderr: stderr}
+}
+
+func (t *Terminal) Emit(e Event) {
+	switch e.Type {
+	case Message:
+		w := t.stdout
+		if e.Level == LevelWarning || e.Level == LevelError {
+			w = t.stderr
+		}
+		fmt.Fprintln(w, e.Text)
+	case RequestStarted:
+		t.requestStart = time.Now()
+		t.printedHeader = false
+		t.startSpinner()
+	case ModelMessage:
+		if t.stopSpinner() {
+			t.printRequestDone()
+		}
+		if !t.printedHeader {
+			fmt.Fprintf(t.stdout, "\x1b[34m\nModel message:\n\x1b[0m")
+			t.printedHeader = true
+		}
+		fmt.Fprint(t.stdout, e.Text)
+	case RequestFinished:
+		spinning := t.stopSpinner()
+		if e.Error != "" {
+			fmt.Fprintln(t.stdout)
+			return
+		}
+		if spinning {
+			t.printRequestDone()
+		}
+		if t.printedHeader {
+			fmt.Fprint(t.stdout, "\n\n")
+		}
+	case Usage:
+		fmt.Fprintf(t.stdout, "\x1b[90m%s\x1b[0m\n", e.Text)
+	case Diff:
+		if e.Text != "" {
+			fmt.Fprintln(t.stdout, e.Text)
+		}
+		fmt.Fprintln(t.stdout, util.ColorizeDiff(e.Diff))
+	case CommandOutput:
+		if e.Stream == "stderr" {
+			fmt.Fprint(t.stderr, e.Text)
+		} else {
+			fmt.Fprint(t.stdout, e.Text)
+		}
+	case ConfirmationNeeded:
+		if len(e.Choices) > 0 {
+			fmt.Fprintf(t.stdout, "%s [%s]: ", e.Prompt, strings.Join(e.Choices, ","))
+		} else {
+			fmt.Fprintf(t.stdout, "%s (y/N): ", e.Prompt)
+		}
+		if e.Answer != "" {
+			fmt.Fprintln(t.stdout, e.Answer)
+		}
+	case TaskFinished:
+		fmt.Fprintf(t.stdout, "\n%s\n", e.Text)
+	case QuestionAsked:
+		fmt.Fprintf(t.stdout, "Question: %s\nYour answer: ", e.Prompt)
+		if e.Answer != "" {
+			fmt.Fprintln(t.stdout, e.Answer)
+		}
+	}
+}
+
+func (t *Terminal) printRequestDone() {
+	fmt.Fprintf(t.stdout, "\r\x1b[32mExecuting AI request... ✓ (%.1f s)\x1b[0m\n", time.Since(t.requestStart).Seconds())
+}
+
+func (t *Terminal) startSpinner() {
+	t.spinnerDone = make(chan struct{})
+	t.spinnerStopped = make(chan struct{})
+	go func(done, stopped chan struct{}) {
+		defer close(stopped)
+		spinnerChars := []string{"|", "/", "-", "\\"}
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+				elapsed := time.Since(t.requestStart).Seconds()
+				fmt.Fprintf(t.stdout, "\r\x1b[36mExecuting AI request... %s (%.1f s)\x1b[0m", spinnerChars[i%len(spinnerChars)], elapsed)
+				time.Sleep(100 * time.Millisecond)
+			}
+		}
+	}(t.spinnerDone, t.spinnerStopped)
+}
+
+// stopSpinner stops the spinner and reports whether it was running.
+func (t *Terminal) stopSpinner() bool {
+	if t.spinnerDone == nil {
+		return false
+	}
+	close(t.spinnerDone)
+	<-t.spinnerStopped
+	t.spinnerDone = nil
+	return true
+}
@@ -16,6 +16,10 @@ func main() {
 
 	rootCmd.AddCommand(cmd.NewFileCmd())
 	rootCmd.AddCommand(cmd.NewDoCmd())
+	rootCmd.AddCommand(cmd.NewUndoCmd())
+	rootCmd.AddCommand(cmd.NewCheckpointsCmd())
+	rootCmd.AddCommand(cmd.NewResumeCmd())
+	rootCmd.AddCommand(cmd.NewSessionsCmd())
 	rootCmd.AddCommand(extension.NewVersionCobraCmd(
 		extension.WithUpgradeNotice("rofleksey", "dwight"),
 	))
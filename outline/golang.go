@@ -0,0 +1,155 @@
+package outline
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+)
+
+const maxFieldsLine = 160
+
+// Go returns a symbol outline of a Go source file: the package clause followed by types, functions,
+// methods, constants and variables with their signatures and the first sentence of their doc comments.
+// Struct fields and interface methods are included from depth 2 on.
+func Go(filename string, src []byte, depth int) ([]string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{withDoc("package "+file.Name.Name, file.Doc)}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			lines = append(lines, withDoc(funcSignature(fset, d), d.Doc))
+		case *ast.GenDecl:
+			lines = append(lines, genDecl(fset, d, depth)...)
+		}
+	}
+	return lines, nil
+}
+
+func funcSignature(fset *token.FileSet, d *ast.FuncDecl) string {
+	sig := &ast.FuncDecl{Recv: d.Recv, Name: d.Name, Type: d.Type}
+	return render(fset, sig)
+}
+
+func genDecl(fset *token.FileSet, d *ast.GenDecl, depth int) []string {
+	var lines []string
+	for _, spec := range d.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			doc := s.Doc
+			if doc == nil && len(d.Specs) == 1 {
+				doc = d.Doc
+			}
+			lines = append(lines, withDoc(typeSpec(fset, s, depth), doc))
+		case *ast.ValueSpec:
+			doc := s.Doc
+			if doc == nil && len(d.Specs) == 1 {
+				doc = d.Doc
+			}
+			names := make([]string, 0, len(s.Names))
+			for _, name := range s.Names {
+				names = append(names, name.Name)
+			}
+			line := d.Tok.String() + " " + strings.Join(names, ", ")
+			if s.Type != nil {
+				line += " " + render(fset, s.Type)
+			}
+			lines = append(lines, withDoc(line, doc))
+		}
+	}
+	return lines
+}
+
+// typeSpec renders struct and interface types on a single line with their fields or methods.
+func typeSpec(fset *token.FileSet, s *ast.TypeSpec, depth int) string {
+	name := s.Name.Name
+	if s.TypeParams != nil {
+		params := make([]string, 0, len(s.TypeParams.List))
+		for _, field := range s.TypeParams.List {
+			names := make([]string, 0, len(field.Names))
+			for _, n := range field.Names {
+				names = append(names, n.Name)
+			}
+			params = append(params, strings.Join(names, ", ")+" "+render(fset, field.Type))
+		}
+		name += "[" + strings.Join(params, ", ") + "]"
+	}
+	assign := " "
+	if s.Assign.IsValid() {
+		assign = " = "
+	}
+
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		if depth < 2 {
+			return "type " + name + " struct"
+		}
+		return "type " + name + " struct" + fieldList(fset, t.Fields, false)
+	case *ast.InterfaceType:
+		if depth < 2 {
+			return "type " + name + " interface"
+		}
+		return "type " + name + " interface" + fieldList(fset, t.Methods, true)
+	}
+	return "type " + name + assign + render(fset, s.Type)
+}
+
+func fieldList(fset *token.FileSet, fields *ast.FieldList, methods bool) string {
+	if fields == nil || len(fields.List) == 0 {
+		return "{}"
+	}
+
+	parts := make([]string, 0, len(fields.List))
+	for _, field := range fields.List {
+		typ := render(fset, field.Type)
+		if methods {
+			typ = strings.TrimPrefix(typ, "func")
+		}
+		if len(field.Names) == 0 {
+			parts = append(parts, typ)
+			continue
+		}
+		names := make([]string, 0, len(field.Names))
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+		sep := " "
+		if methods {
+			sep = ""
+		}
+		parts = append(parts, strings.Join(names, ", ")+sep+typ)
+	}
+
+	line := strings.Join(parts, "; ")
+	if len(line) > maxFieldsLine {
+		line = line[:maxFieldsLine] + " ..."
+	}
+	return "{ " + line + " }"
+}
+
+func render(fset *token.FileSet, node ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+func withDoc(line string, doc *ast.CommentGroup) string {
+	if doc == nil {
+		return line
+	}
+	sentence := firstSentence(doc.Text())
+	if sentence == "" {
+		return line
+	}
+	return line + " // " + sentence
+}
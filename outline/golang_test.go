@@ -0,0 +1,81 @@
+package outline
+
+import "testing"
+
+const goSource = `// Package store keeps things.
+//
+// More details.
+package store
+
+import "fmt"
+
+// DefaultLimit is the default page size. It can be overridden.
+const DefaultLimit = 50
+
+const (
+	a, b = 1, 2
+	// c is documented.
+	c string = "x"
+)
+
+var ErrNotFound = fmt.Errorf("not found")
+
+// Store holds items.
+type Store[K comparable, V any] struct {
+	items map[K]V
+	Limit int
+	fmt.Stringer
+}
+
+type Getter interface {
+	Get(key string) (string, error)
+	fmt.Stringer
+}
+
+type Empty struct{}
+
+type ID = string
+
+// New creates a store.
+func New[K comparable, V any]() *Store[K, V] {
+	return &Store[K, V]{}
+}
+
+// Get returns an item.
+func (s *Store[K, V]) Get(key K) (V, bool) {
+	v, ok := s.items[key]
+	return v, ok
+}
+`
+
+func TestGo(t *testing.T) {
+	top := func(store, getter, empty string) []string {
+		return []string{
+			"package store // Package store keeps things.",
+			"const DefaultLimit // DefaultLimit is the default page size.",
+			"const a, b",
+			"const c string // c is documented.",
+			"var ErrNotFound",
+			store + " // Store holds items.",
+			getter,
+			empty,
+			"type ID = string",
+			"func New[K comparable, V any]() *Store[K, V] // New creates a store.",
+			"func (s *Store[K, V]) Get(key K) (V, bool) // Get returns an item.",
+		}
+	}
+	checkOutline(t, Go, "store.go", goSource, map[int][]string{
+		1: top("type Store[K comparable, V any] struct", "type Getter interface", "type Empty struct"),
+		2: top(
+			"type Store[K comparable, V any] struct{ items map[K]V; Limit int; fmt.Stringer }",
+			"type Getter interface{ Get(key string) (string, error); fmt.Stringer }",
+			"type Empty struct{}",
+		),
+	})
+}
+
+func TestGoSyntaxError(t *testing.T) {
+	if _, err := Go("broken.go", []byte("package broken\n\nfunc {"), 1); err == nil {
+		t.Error("Go() accepted a file with a syntax error")
+	}
+}
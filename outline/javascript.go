@@ -0,0 +1,79 @@
+package outline
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	jsContainer = regexp.MustCompile(`^(export\s+)?(default\s+)?(declare\s+)?(abstract\s+)?(class|interface|enum|namespace|module)\s+[\w$.]+`)
+	jsFunction  = regexp.MustCompile(`^(export\s+)?(default\s+)?(declare\s+)?(async\s+)?function\b`)
+	jsType      = regexp.MustCompile(`^(export\s+)?(declare\s+)?type\s+[\w$]+`)
+	jsArrow     = regexp.MustCompile(`^(export\s+)?(const|let|var)\s+[\w$]+\s*(:[^=]+)?=\s*(async\s+)?(\([^)]*\)|[\w$]+)\s*(:[^=]+)?=>`)
+	jsVariable  = regexp.MustCompile(`^export\s+(const|let|var)\s+[\w$]+`)
+	jsDefault   = regexp.MustCompile(`^export\s+default\b`)
+	jsMethod    = regexp.MustCompile(`^((public|private|protected|static|readonly|abstract|override|async|get|set)\s+)*\*?[#\w$]+\??\s*(<[^>]*>)?\s*\(`)
+	jsProperty  = regexp.MustCompile(`^((public|private|protected|static|readonly|abstract|override|declare)\s+)*[#\w$]+[?!]?\s*[:=]`)
+	jsKeyword   = regexp.MustCompile(`^(if|for|while|switch|catch|return|function|new|await|typeof|super|this)\b`)
+)
+
+// JavaScript outlines JavaScript and TypeScript sources: functions, classes, interfaces, types, enums
+// and exported variables at depth 1, class and interface members at depth 2.
+func JavaScript(_ string, src []byte, depth int) ([]string, error) {
+	scanner := &braceScanner{lineComments: []string{"//"}, quotes: "\"'`"}
+	raw := strings.Split(string(src), "\n")
+	lines := make([]string, len(raw))
+	for i, line := range raw {
+		lines[i] = scanner.code(line)
+	}
+	scanner = &braceScanner{}
+
+	var (
+		out []string
+		// container is set while inside the body of a top-level class, interface or enum.
+		container bool
+		// skip is the last line of a multi-line declaration already added to the outline.
+		skip = -1
+	)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		level := scanner.depth
+
+		switch {
+		case trimmed == "" || i <= skip:
+		case level == 0:
+			container = false
+			switch {
+			case jsContainer.MatchString(trimmed):
+				container = true
+				var decl string
+				decl, skip = declaration(lines, i, "{")
+				out = append(out, decl)
+			case jsFunction.MatchString(trimmed):
+				var decl string
+				decl, skip = declaration(lines, i, "{;")
+				out = append(out, decl)
+			case jsArrow.MatchString(trimmed):
+				decl := jsArrow.FindString(trimmed)
+				out = append(out, strings.TrimSpace(strings.TrimSuffix(decl, "=>")))
+			case jsType.MatchString(trimmed), jsVariable.MatchString(trimmed), jsDefault.MatchString(trimmed):
+				out = append(out, cutInitializer(collapse(trimmed)))
+			}
+		case level == 1 && container && depth >= 2:
+			switch {
+			case jsKeyword.MatchString(trimmed):
+			case jsMethod.MatchString(trimmed):
+				var decl string
+				decl, skip = declaration(lines, i, "{;")
+				out = append(out, indent(1, decl))
+			case jsProperty.MatchString(trimmed):
+				out = append(out, indent(1, cutInitializer(strings.TrimSuffix(collapse(trimmed), ","))))
+			case !strings.ContainsAny(trimmed, "{}()"):
+				// Enum members.
+				out = append(out, indent(1, cutInitializer(strings.TrimSuffix(collapse(trimmed), ","))))
+			}
+		}
+		scanner.advance(line)
+	}
+	return out, nil
+}
@@ -0,0 +1,91 @@
+package outline
+
+import "testing"
+
+const typeScriptSource = `import { x } from "./x";
+
+/* block { comment */
+export interface User {
+  id: number;
+  name?: string;
+  greet(other: User): string;
+}
+
+export type ID = string | number;
+
+export enum Color {
+  Red = "red",
+  Green,
+}
+
+export class Service extends Base implements Runner {
+  private readonly cache = new Map<string, User>();
+  static count: number = 0;
+
+  constructor(private http: Http) {
+    super();
+    if (x) {
+      run();
+    }
+  }
+
+  async load(id: ID): Promise<User> {
+    const s = "}";
+    return this.http.get(` + "`/users/${id}`" + `);
+  }
+
+  get size(): number {
+    return this.cache.size;
+  }
+}
+
+export async function main(
+  args: string[],
+): Promise<void> {
+  console.log("{");
+}
+
+export const handler = async (event: Event): Promise<void> => {
+  await main([]);
+};
+
+export const VERSION = "1.0";
+const internal = 1;
+
+export default Service;
+`
+
+func TestJavaScript(t *testing.T) {
+	checkOutline(t, JavaScript, "service.ts", typeScriptSource, map[int][]string{
+		1: {
+			"export interface User",
+			"export type ID",
+			"export enum Color",
+			"export class Service extends Base implements Runner",
+			"export async function main( args: string[], ): Promise<void>",
+			"export const handler = async (event: Event): Promise<void>",
+			"export const VERSION",
+			"export default Service",
+		},
+		2: {
+			"export interface User",
+			"  id: number",
+			"  name?: string",
+			"  greet(other: User): string",
+			"export type ID",
+			"export enum Color",
+			"  Red",
+			"  Green",
+			"export class Service extends Base implements Runner",
+			"  private readonly cache",
+			"  static count: number",
+			"  constructor(private http: Http)",
+			"  async load(id: ID): Promise<User>",
+			"  get size(): number",
+			"export async function main( args: string[], ): Promise<void>",
+			"export const handler = async (event: Event): Promise<void>",
+			"export const VERSION",
+			"export default Service",
+		},
+	})
+}
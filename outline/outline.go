@@ -0,0 +1,107 @@
+package outline
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Extractor builds the outline of one kind of source file. Depth limits how far into nested
+// declarations it descends: 1 lists only top-level declarations, 2 adds their members and so on.
+type Extractor struct {
+	// Language is used as the code fence language in the project structure.
+	Language string
+	Extract  func(filename string, src []byte, depth int) ([]string, error)
+}
+
+var (
+	typeScriptExtractor = Extractor{Language: "typescript", Extract: JavaScript}
+	javaScriptExtractor = Extractor{Language: "javascript", Extract: JavaScript}
+	yamlExtractor       = Extractor{Language: "yaml", Extract: YAML}
+)
+
+// registry maps lower-case file extensions to their extractors.
+var registry = map[string]Extractor{
+	".go":    {Language: "go", Extract: Go},
+	".proto": {Language: "protobuf", Extract: Proto},
+	".sql":   {Language: "sql", Extract: SQL},
+	".py":    {Language: "python", Extract: Python},
+	".ts":    typeScriptExtractor,
+	".tsx":   typeScriptExtractor,
+	".mts":   typeScriptExtractor,
+	".cts":   typeScriptExtractor,
+	".js":    javaScriptExtractor,
+	".jsx":   javaScriptExtractor,
+	".mjs":   javaScriptExtractor,
+	".cjs":   javaScriptExtractor,
+	".yaml":  yamlExtractor,
+	".yml":   yamlExtractor,
+}
+
+// For returns the extractor registered for the extension of filename.
+func For(filename string) (Extractor, bool) {
+	x, ok := registry[normalize(filepath.Ext(filename))]
+	return x, ok
+}
+
+// Extensions returns all registered extensions in sorted order.
+func Extensions() []string {
+	exts := make([]string, 0, len(registry))
+	for ext := range registry {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// Enabled reports whether filename should be outlined given a configured list of extensions;
+// an empty list enables every registered extension.
+func Enabled(filename string, extensions []string) bool {
+	if _, ok := For(filename); !ok {
+		return false
+	}
+	if len(extensions) == 0 {
+		return true
+	}
+	ext := normalize(filepath.Ext(filename))
+	for _, e := range extensions {
+		if normalize(e) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func normalize(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// maxDocLine caps doc comment excerpts so a long first sentence does not dominate the outline.
+const maxDocLine = 120
+
+// firstSentence returns the first sentence of the first paragraph of a doc comment.
+func firstSentence(doc string) string {
+	paragraph, _, _ := strings.Cut(strings.TrimSpace(doc), "\n\n")
+	sentence := collapse(paragraph)
+	if i := strings.Index(sentence, ". "); i >= 0 {
+		sentence = sentence[:i+1]
+	}
+	if len(sentence) > maxDocLine {
+		sentence = sentence[:maxDocLine] + "..."
+	}
+	return sentence
+}
+
+// indent prefixes line with two spaces per nesting level below the top level.
+func indent(level int, line string) string {
+	return strings.Repeat("  ", level) + line
+}
+
+// collapse joins a possibly multi-line declaration into one line with single spaces.
+func collapse(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,54 @@
+package outline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFor(t *testing.T) {
+	tests := map[string]string{
+		"main.go":       "go",
+		"api.PROTO":     "protobuf",
+		"schema.sql":    "sql",
+		"app.py":        "python",
+		"component.tsx": "typescript",
+		"index.mjs":     "javascript",
+		"config.yml":    "yaml",
+	}
+	for filename, language := range tests {
+		x, ok := For(filename)
+		if !ok || x.Language != language {
+			t.Errorf("For(%q) = %q, %v, want %q", filename, x.Language, ok, language)
+		}
+	}
+	if _, ok := For("README.md"); ok {
+		t.Error("For(README.md) found an extractor")
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	if !Enabled("a.go", nil) {
+		t.Error("registered extensions should be enabled by default")
+	}
+	if !Enabled("a.py", []string{"py", ".go"}) || Enabled("a.ts", []string{"py", ".go"}) {
+		t.Error("configured extensions are not honored")
+	}
+	if Enabled("a.txt", []string{".txt"}) {
+		t.Error("extensions without an extractor cannot be enabled")
+	}
+}
+
+// checkOutline compares the outline of src at each depth with the expected lines.
+func checkOutline(t *testing.T, extract func(string, []byte, int) ([]string, error), filename, src string, want map[int][]string) {
+	t.Helper()
+	for depth := 1; depth <= len(want); depth++ {
+		got, err := extract(filename, []byte(src), depth)
+		if err != nil {
+			t.Fatalf("depth %d: %v", depth, err)
+		}
+		if !reflect.DeepEqual(got, want[depth]) {
+			t.Errorf("depth %d: outline =\n%s\nwant\n%s", depth, strings.Join(got, "\n"), strings.Join(want[depth], "\n"))
+		}
+	}
+}
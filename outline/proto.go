@@ -0,0 +1,47 @@
+package outline
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	protoDecl  = regexp.MustCompile(`^(package|message|enum|service|oneof|extend|rpc)\b`)
+	protoField = regexp.MustCompile(`^((repeated|optional|required)\s+)?(map\s*<[^>]+>|[\w.]+)\s+\w+\s*=\s*\d+`)
+	protoValue = regexp.MustCompile(`^\w+\s*=\s*-?\d+`)
+)
+
+// Proto outlines protobuf definitions: the package, messages, enums and services at depth 1,
+// fields, enum values, nested types and RPCs below that.
+func Proto(_ string, src []byte, depth int) ([]string, error) {
+	scanner := &braceScanner{lineComments: []string{"//"}, quotes: `"'`}
+	raw := strings.Split(string(src), "\n")
+	lines := make([]string, len(raw))
+	for i, line := range raw {
+		lines[i] = scanner.code(line)
+	}
+
+	var (
+		out   []string
+		level int
+	)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && level < depth {
+			switch {
+			case protoDecl.MatchString(trimmed):
+				decl, _ := declaration(lines, i, "{;")
+				out = append(out, indent(level, decl))
+			case level > 0 && (protoField.MatchString(trimmed) || protoValue.MatchString(trimmed)):
+				field, _ := declaration(lines, i, ";")
+				if j := strings.Index(field, " ["); j >= 0 {
+					field = field[:j]
+				}
+				out = append(out, indent(level, field))
+			}
+		}
+		scanner.advance(line)
+		level = scanner.depth
+	}
+	return out, nil
+}
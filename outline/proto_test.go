@@ -0,0 +1,79 @@
+package outline
+
+import "testing"
+
+const protoSource = `syntax = "proto3";
+
+package shop.v1;
+
+import "google/protobuf/timestamp.proto";
+
+// Order is an order { with braces in a comment.
+message Order {
+  string id = 1;
+  repeated Item items = 2 [deprecated = true];
+  map<string, string> labels = 3;
+
+  message Item {
+    string sku = 1;
+    int32 quantity = 2;
+  }
+
+  oneof payment {
+    string card = 4;
+    string invoice = 5;
+  }
+}
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  STATUS_DONE = 1;
+}
+
+service Orders {
+  rpc Get(GetRequest) returns (Order);
+  rpc Watch(WatchRequest) returns (stream Order) {
+    option deprecated = true;
+  }
+}
+`
+
+func TestProto(t *testing.T) {
+	checkOutline(t, Proto, "shop.proto", protoSource, map[int][]string{
+		1: {"package shop.v1", "message Order", "enum Status", "service Orders"},
+		2: {
+			"package shop.v1",
+			"message Order",
+			"  string id = 1",
+			"  repeated Item items = 2",
+			"  map<string, string> labels = 3",
+			"  message Item",
+			"  oneof payment",
+			"enum Status",
+			"  STATUS_UNSPECIFIED = 0",
+			"  STATUS_DONE = 1",
+			"service Orders",
+			"  rpc Get(GetRequest) returns (Order)",
+			"  rpc Watch(WatchRequest) returns (stream Order)",
+		},
+		3: {
+			"package shop.v1",
+			"message Order",
+			"  string id = 1",
+			"  repeated Item items = 2",
+			"  map<string, string> labels = 3",
+			"  message Item",
+			"    string sku = 1",
+			"    int32 quantity = 2",
+			"  oneof payment",
+			"    string card = 4",
+			"    string invoice = 5",
+			"enum Status",
+			"  STATUS_UNSPECIFIED = 0",
+			"  STATUS_DONE = 1",
+			"service Orders",
+			"  rpc Get(GetRequest) returns (Order)",
+			"  rpc Watch(WatchRequest) returns (stream Order)",
+		},
+	})
+}
@@ -0,0 +1,108 @@
+package outline
+
+import (
+	"regexp"
+	"strings"
+)
+
+var pythonDecl = regexp.MustCompile(`^(\s*)(async\s+def|def|class)\s+\w+`)
+
+// Python outlines classes and functions with their signatures and the first sentence of their docstrings.
+// Nesting follows indentation, so methods are at depth 2 and nested functions below that.
+func Python(_ string, src []byte, depth int) ([]string, error) {
+	lines := strings.Split(string(src), "\n")
+
+	var (
+		out      []string
+		indents  []int
+		inString string
+	)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if inString != "" {
+			if strings.Contains(line, inString) {
+				inString = ""
+			}
+			continue
+		}
+		if quote := openTripleQuote(line); quote != "" {
+			inString = quote
+			continue
+		}
+
+		m := pythonDecl.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		width := len(strings.ReplaceAll(m[1], "\t", "    "))
+		for len(indents) > 0 && indents[len(indents)-1] >= width {
+			indents = indents[:len(indents)-1]
+		}
+		level := len(indents)
+		indents = append(indents, width)
+		if level >= depth {
+			continue
+		}
+
+		header, end := pythonHeader(lines, i)
+		if doc := pythonDocstring(lines, end+1); doc != "" {
+			header += " # " + doc
+		}
+		out = append(out, indent(level, header))
+		i = end
+	}
+	return out, nil
+}
+
+// pythonHeader returns the declaration starting at line start without the trailing colon, and the
+// index of the line it ends on.
+func pythonHeader(lines []string, start int) (string, int) {
+	var header strings.Builder
+	parens := 0
+	for i := start; i < len(lines) && i < start+20; i++ {
+		line := lines[i]
+		if j := strings.Index(line, "#"); j >= 0 {
+			line = line[:j]
+		}
+		parens += strings.Count(line, "(") + strings.Count(line, "[") - strings.Count(line, ")") - strings.Count(line, "]")
+		header.WriteString(line)
+		header.WriteByte(' ')
+		if parens <= 0 && strings.HasSuffix(strings.TrimSpace(line), ":") {
+			return strings.TrimSuffix(collapse(header.String()), ":"), i
+		}
+	}
+	return collapse(header.String()), start
+}
+
+func pythonDocstring(lines []string, start int) string {
+	for i := start; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" {
+			continue
+		}
+		for _, quote := range []string{`"""`, `'''`} {
+			if !strings.HasPrefix(trimmed, quote) {
+				continue
+			}
+			var text strings.Builder
+			text.WriteString(strings.TrimPrefix(trimmed, quote))
+			for j := i + 1; j < len(lines) && !strings.Contains(text.String(), quote) && strings.TrimSpace(lines[j]) != ""; j++ {
+				text.WriteString(" " + strings.TrimSpace(lines[j]))
+			}
+			doc, _, _ := strings.Cut(text.String(), quote)
+			return firstSentence(doc)
+		}
+		return ""
+	}
+	return ""
+}
+
+// openTripleQuote returns the quote of a triple-quoted string that starts but does not end on line.
+func openTripleQuote(line string) string {
+	for _, quote := range []string{`"""`, `'''`} {
+		if strings.Count(line, quote)%2 == 1 {
+			return quote
+		}
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package outline
+
+import "testing"
+
+const pythonSource = `"""Module docstring.
+
+def not_a_function():
+"""
+import os
+
+
+def top(a, b=1):
+    """Return the top. More text."""
+    return a
+
+
+class Service(Base):
+    '''A service.'''
+
+    def __init__(self, name):  # constructor
+        self.name = name
+
+    async def fetch(
+        self,
+        url: str,
+    ) -> bytes:
+        """Fetch a URL.
+        Second line.
+        """
+
+        def inner():
+            pass
+
+        return b""
+
+
+async def main():
+    pass
+`
+
+func TestPython(t *testing.T) {
+	checkOutline(t, Python, "service.py", pythonSource, map[int][]string{
+		1: {
+			"def top(a, b=1) # Return the top.",
+			"class Service(Base) # A service.",
+			"async def main()",
+		},
+		2: {
+			"def top(a, b=1) # Return the top.",
+			"class Service(Base) # A service.",
+			"  def __init__(self, name)",
+			"  async def fetch( self, url: str, ) -> bytes # Fetch a URL.",
+			"async def main()",
+		},
+		3: {
+			"def top(a, b=1) # Return the top.",
+			"class Service(Base) # A service.",
+			"  def __init__(self, name)",
+			"  async def fetch( self, url: str, ) -> bytes # Fetch a URL.",
+			"    def inner()",
+			"async def main()",
+		},
+	})
+}
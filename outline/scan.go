@@ -0,0 +1,124 @@
+package outline
+
+import "strings"
+
+// braceScanner tracks the brace nesting of C-like sources line by line, ignoring braces inside
+// comments and string literals. It is a heuristic, not a parser, and only has to be good enough
+// to tell top-level declarations from their members.
+type braceScanner struct {
+	depth        int
+	inBlock      bool
+	inTemplate   bool
+	lineComments []string
+	quotes       string
+}
+
+// code returns line with comments and string contents removed, keeping the quotes themselves.
+func (s *braceScanner) code(line string) string {
+	var out strings.Builder
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case s.inBlock:
+			if strings.HasPrefix(line[i:], "*/") {
+				s.inBlock = false
+				i++
+			}
+			continue
+		case s.inTemplate:
+			if c == '\\' {
+				i++
+			} else if c == '`' {
+				s.inTemplate = false
+				out.WriteByte(c)
+			}
+			continue
+		case strings.HasPrefix(line[i:], "/*"):
+			s.inBlock = true
+			i++
+			continue
+		}
+
+		for _, prefix := range s.lineComments {
+			if strings.HasPrefix(line[i:], prefix) {
+				return out.String()
+			}
+		}
+
+		if c == '`' && strings.IndexByte(s.quotes, '`') >= 0 {
+			s.inTemplate = true
+			out.WriteByte(c)
+			continue
+		}
+		if strings.IndexByte(s.quotes, c) >= 0 {
+			out.WriteByte(c)
+			for i++; i < len(line) && line[i] != c; i++ {
+				if line[i] == '\\' {
+					i++
+				}
+			}
+			if i < len(line) {
+				out.WriteByte(c)
+			}
+			continue
+		}
+		out.WriteByte(c)
+	}
+	return out.String()
+}
+
+// advance updates the nesting depth with the braces of an already stripped line.
+func (s *braceScanner) advance(code string) {
+	s.depth += strings.Count(code, "{") - strings.Count(code, "}")
+	if s.depth < 0 {
+		s.depth = 0
+	}
+}
+
+// declaration joins lines starting at start until the declaration header ends with one of the
+// terminators outside of parentheses, looking at most a few lines ahead. The terminator itself
+// is not included. It also returns the index of the last line that belongs to the header.
+func declaration(lines []string, start int, terminators string) (string, int) {
+	var header strings.Builder
+	parens := 0
+	end := start
+	for i := start; i < len(lines) && i < start+8; i++ {
+		end = i
+		for _, c := range lines[i] {
+			switch {
+			case parens <= 0 && strings.ContainsRune(terminators, c):
+				return collapse(header.String()), i
+			case c == '(' || c == '[':
+				parens++
+			case c == ')' || c == ']':
+				parens--
+			}
+			header.WriteRune(c)
+		}
+		header.WriteByte(' ')
+		if parens <= 0 && i > start {
+			break
+		}
+	}
+	return collapse(header.String()), end
+}
+
+// cutInitializer removes an assignment from a declaration, keeping the name and type annotation.
+// Comparison operators and arrows are not assignments.
+func cutInitializer(decl string) string {
+	for i := 0; i < len(decl); i++ {
+		if decl[i] != '=' {
+			continue
+		}
+		if i+1 < len(decl) && (decl[i+1] == '>' || decl[i+1] == '=') {
+			i++
+			continue
+		}
+		if i > 0 && strings.IndexByte("!<>=", decl[i-1]) >= 0 {
+			continue
+		}
+		decl = decl[:i]
+		break
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(decl), ";"))
+}
@@ -0,0 +1,115 @@
+package outline
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	sqlStatement  = regexp.MustCompile(`(?i)^(CREATE|ALTER|DROP|COMMENT\s+ON)\b`)
+	sqlTable      = regexp.MustCompile(`(?i)^CREATE\s+(OR\s+REPLACE\s+)?((GLOBAL|LOCAL)\s+)?((TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\b`)
+	sqlConstraint = regexp.MustCompile(`(?i)^(CONSTRAINT|PRIMARY|FOREIGN|UNIQUE|CHECK|EXCLUDE|INDEX|KEY)\b`)
+	sqlMigration  = regexp.MustCompile(`(?i)^--\s*\+(goose|migrate)\s+(Up|Down)\b`)
+	sqlBody       = regexp.MustCompile(`(?i)\s(AS|RETURNS|BEGIN)\b`)
+)
+
+// SQL outlines schema statements such as CREATE, ALTER and DROP at depth 1 and the columns and
+// constraints of created tables at depth 2. Migration direction markers are kept as well.
+func SQL(_ string, src []byte, depth int) ([]string, error) {
+	lines := strings.Split(string(src), "\n")
+
+	var (
+		out     []string
+		inTable bool
+		parens  int
+	)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if sqlMigration.MatchString(trimmed) {
+			out = append(out, trimmed)
+			continue
+		}
+		if j := strings.Index(trimmed, "--"); j >= 0 {
+			trimmed = strings.TrimSpace(trimmed[:j])
+		}
+		if trimmed == "" {
+			continue
+		}
+
+		if inTable {
+			if parens == 1 && depth >= 2 {
+				out = appendColumns(out, trimmed)
+			}
+			parens += strings.Count(trimmed, "(") - strings.Count(trimmed, ")")
+			if parens <= 0 {
+				inTable = false
+			}
+			continue
+		}
+
+		if !sqlStatement.MatchString(trimmed) {
+			continue
+		}
+		statement, _ := declaration(lines, i, "(;")
+		if loc := sqlBody.FindStringIndex(statement); loc != nil {
+			statement = statement[:loc[0]]
+		}
+		out = append(out, statement)
+
+		if sqlTable.MatchString(trimmed) {
+			if open := strings.Index(trimmed, "("); open >= 0 {
+				// Columns may start on the same line as CREATE TABLE.
+				rest := strings.TrimSpace(trimmed[open+1:])
+				parens = 1 + strings.Count(rest, "(") - strings.Count(rest, ")")
+				if depth >= 2 {
+					out = appendColumns(out, rest)
+				}
+				inTable = parens > 0
+			} else {
+				inTable, parens = true, 0
+			}
+		}
+	}
+	return out, nil
+}
+
+// appendColumns adds the columns and constraints defined in text, which starts inside the column list of
+// a table, to the outline. Definitions end at commas outside of parentheses, and the list at its closing one.
+func appendColumns(out []string, text string) []string {
+	add := func(column string) {
+		if column = strings.TrimSpace(column); column != "" {
+			out = append(out, indent(1, sqlColumn(column)))
+		}
+	}
+
+	parens, start := 0, 0
+	for i, c := range text {
+		switch {
+		case c == '(':
+			parens++
+		case c == ')' && parens > 0:
+			parens--
+		case c == ')':
+			add(text[start:i])
+			return out
+		case c == ',' && parens == 0:
+			add(text[start:i])
+			start = i + 1
+		}
+	}
+	add(text[start:])
+	return out
+}
+
+// sqlColumn reduces a column definition to its name and type; table constraints are kept whole.
+func sqlColumn(line string) string {
+	line = strings.TrimSuffix(strings.TrimSpace(strings.TrimSuffix(line, ";")), ",")
+	if sqlConstraint.MatchString(line) {
+		return collapse(line)
+	}
+	fields := strings.Fields(line)
+	if len(fields) > 2 {
+		fields = fields[:2]
+	}
+	return strings.TrimSuffix(strings.Join(fields, " "), ",")
+}
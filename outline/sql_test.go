@@ -0,0 +1,61 @@
+package outline
+
+import "testing"
+
+const sqlSource = `-- +goose Up
+CREATE TABLE users (
+    id BIGSERIAL PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE, -- login
+    created_at TIMESTAMPTZ DEFAULT now(),
+    CONSTRAINT email_lower CHECK (email = lower(email))
+);
+
+CREATE TABLE tags (id INT PRIMARY KEY, name TEXT);
+
+CREATE INDEX users_email_idx ON users (email);
+
+ALTER TABLE users ADD COLUMN name TEXT;
+
+CREATE OR REPLACE FUNCTION touch() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at = now();
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT 1;
+
+-- +goose Down
+DROP TABLE users;
+`
+
+func TestSQL(t *testing.T) {
+	checkOutline(t, SQL, "001_users.sql", sqlSource, map[int][]string{
+		1: {
+			"-- +goose Up",
+			"CREATE TABLE users",
+			"CREATE TABLE tags",
+			"CREATE INDEX users_email_idx ON users",
+			"ALTER TABLE users ADD COLUMN name TEXT",
+			"CREATE OR REPLACE FUNCTION touch",
+			"-- +goose Down",
+			"DROP TABLE users",
+		},
+		2: {
+			"-- +goose Up",
+			"CREATE TABLE users",
+			"  id BIGSERIAL",
+			"  email TEXT",
+			"  created_at TIMESTAMPTZ",
+			"  CONSTRAINT email_lower CHECK (email = lower(email))",
+			"CREATE TABLE tags",
+			"  id INT",
+			"  name TEXT",
+			"CREATE INDEX users_email_idx ON users",
+			"ALTER TABLE users ADD COLUMN name TEXT",
+			"CREATE OR REPLACE FUNCTION touch",
+			"-- +goose Down",
+			"DROP TABLE users",
+		},
+	})
+}
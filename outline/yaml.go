@@ -0,0 +1,70 @@
+package outline
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	yamlKey         = regexp.MustCompile(`^(\s*)("[^"]*"|'[^']*'|[^\s#'"\-][^:#]*?)\s*:(\s|$)`)
+	yamlBlockScalar = regexp.MustCompile(`:\s*[|>][-+0-9]*\s*(#.*)?$`)
+)
+
+// YAML outlines mapping keys, top-level keys at depth 1 and nested keys below that.
+// Keys inside sequences and block scalars are skipped to keep outlines of large manifests short.
+func YAML(_ string, src []byte, depth int) ([]string, error) {
+	lines := strings.Split(string(src), "\n")
+
+	var (
+		out     []string
+		indents []int
+		// scalarIndent is the indentation of the key owning the block scalar being skipped, or -1.
+		scalarIndent = -1
+		// seqIndent is the indentation of the dashes of the sequence being skipped, or -1.
+		seqIndent = -1
+	)
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		width := len(line) - len(strings.TrimLeft(line, " "))
+
+		if scalarIndent >= 0 {
+			if trimmed == "" || width > scalarIndent {
+				continue
+			}
+			scalarIndent = -1
+		}
+		if seqIndent >= 0 {
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") || width > seqIndent || (width == seqIndent && strings.HasPrefix(trimmed, "-")) {
+				continue
+			}
+			seqIndent = -1
+		}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			seqIndent = width
+			continue
+		}
+		if trimmed == "---" {
+			out = append(out, trimmed)
+			indents = indents[:0]
+			continue
+		}
+
+		m := yamlKey.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		for len(indents) > 0 && indents[len(indents)-1] >= width {
+			indents = indents[:len(indents)-1]
+		}
+		level := len(indents)
+		indents = append(indents, width)
+		if yamlBlockScalar.MatchString(line) {
+			scalarIndent = width
+		}
+
+		if level < depth {
+			out = append(out, indent(level, m[2]+":"))
+		}
+	}
+	return out, nil
+}
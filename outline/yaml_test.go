@@ -0,0 +1,62 @@
+package outline
+
+import "testing"
+
+const yamlSource = `# comment: not a key
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+      volumes: []
+  script: |
+    key: not a key
+    other: value
+  "quoted key": 1
+---
+second: doc
+`
+
+func TestYAML(t *testing.T) {
+	checkOutline(t, YAML, "deployment.yaml", yamlSource, map[int][]string{
+		1: {"apiVersion:", "kind:", "metadata:", "spec:", "---", "second:"},
+		2: {
+			"apiVersion:",
+			"kind:",
+			"metadata:",
+			"  name:",
+			"  labels:",
+			"spec:",
+			"  replicas:",
+			"  template:",
+			"  script:",
+			`  "quoted key":`,
+			"---",
+			"second:",
+		},
+		3: {
+			"apiVersion:",
+			"kind:",
+			"metadata:",
+			"  name:",
+			"  labels:",
+			"    app:",
+			"spec:",
+			"  replicas:",
+			"  template:",
+			"    spec:",
+			"  script:",
+			`  "quoted key":`,
+			"---",
+			"second:",
+		},
+	})
+}
@@ -0,0 +1,302 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	projectFile = ".dwight/policy.yaml"
+	homeFile    = ".dwight/policy.yaml"
+)
+
+type Action string
+
+const (
+	Allow Action = "allow"
+	Ask   Action = "ask"
+	Deny  Action = "deny"
+	// ForceAsk is never configured by rules. It is the verdict for actions that always need the user,
+	// even with --yes, such as pushing or touching paths outside the project root.
+	ForceAsk Action = "force_ask"
+)
+
+// Rule maps commands or paths matching either a glob Pattern or a Regex to an Action.
+// Command globs treat * as any sequence of characters; path globs use doublestar syntax.
+type Rule struct {
+	Pattern string `yaml:"pattern"`
+	Regex   string `yaml:"regex"`
+	Action  Action `yaml:"action"`
+
+	re *regexp.Regexp
+}
+
+type Policy struct {
+	Commands []Rule `yaml:"commands"`
+	Read     []Rule `yaml:"read"`
+	Write    []Rule `yaml:"write"`
+	Search   []Rule `yaml:"search"`
+
+	root string
+}
+
+var (
+	// shellOperators finds the operators of compound commands, so every part has to pass the rules on its
+	// own. The first group matches redirections such as 2>&1 or &>, whose & does not separate commands.
+	shellOperators = regexp.MustCompile(`([<>]&\d*-?|&>>?)|&&|\|\||[;|&\n]`)
+	// redirection matches the redirection operator a field may start with, e.g. >, >>, 2> or &>.
+	redirection = regexp.MustCompile(`^(?:\d+|&)?(?:<|>>?\|?)&?`)
+	// alwaysAsk lists commands that are never auto-approved, whatever the rules or --yes say.
+	alwaysAsk = []*regexp.Regexp{
+		regexp.MustCompile(`\bgit\s+(.*\s)?push\b`),
+	}
+)
+
+// Load reads the project policy and the home policy. Project rules are evaluated first,
+// so they take precedence over home rules. Missing files are not an error.
+func Load() (*Policy, error) {
+	root, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{root: root}
+
+	paths := []string{filepath.Join(root, projectFile)}
+	if home, err := os.UserHomeDir(); err == nil {
+		homePath := filepath.Join(home, homeFile)
+		if homePath != paths[0] {
+			paths = append(paths, homePath)
+		}
+	}
+
+	for _, path := range paths {
+		loaded, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if loaded == nil {
+			continue
+		}
+		p.Commands = append(p.Commands, loaded.Commands...)
+		p.Read = append(p.Read, loaded.Read...)
+		p.Write = append(p.Write, loaded.Write...)
+		p.Search = append(p.Search, loaded.Search...)
+	}
+	return p, nil
+}
+
+func readFile(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("error parsing policy %s: %w", path, err)
+	}
+
+	for _, rules := range [][]Rule{p.Commands, p.Read, p.Write, p.Search} {
+		for i := range rules {
+			if err := rules[i].compile(); err != nil {
+				return nil, fmt.Errorf("invalid rule in policy %s: %w", path, err)
+			}
+		}
+	}
+	return &p, nil
+}
+
+func (r *Rule) compile() error {
+	switch r.Action {
+	case Allow, Ask, Deny:
+	default:
+		return fmt.Errorf("unknown action %q, expected allow, ask or deny", r.Action)
+	}
+	if (r.Pattern == "") == (r.Regex == "") {
+		return fmt.Errorf("rule must have exactly one of pattern or regex")
+	}
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return err
+		}
+		r.re = re
+	}
+	return nil
+}
+
+// Command decides on a shell command. Compound commands are split on shell operators:
+// any denied part denies the whole command and it is only allowed if every part is allowed.
+// Commands with substitutions cannot be analyzed reliably and are never auto-allowed.
+func (p *Policy) Command(command string) Action {
+	command = strings.TrimSpace(command)
+	result := Allow
+	for _, part := range splitCommand(command) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		result = stricter(result, evaluate(p.Commands, part, matchCommandGlob))
+	}
+
+	if result == Allow && (strings.Contains(command, "$(") || strings.Contains(command, "`")) {
+		result = Ask
+	}
+	if p.leavesRoot(command) {
+		result = stricter(result, ForceAsk)
+	}
+	for _, re := range alwaysAsk {
+		if re.MatchString(command) {
+			result = stricter(result, ForceAsk)
+		}
+	}
+	// A deny rule may target the full command line, e.g. a regex spanning an operator.
+	return stricter(result, evaluateStrict(p.Commands, command, matchCommandGlob))
+}
+
+// splitCommand splits a compound command on its shell operators.
+func splitCommand(command string) []string {
+	var parts []string
+	start := 0
+	for _, m := range shellOperators.FindAllStringSubmatchIndex(command, -1) {
+		if m[2] >= 0 {
+			continue
+		}
+		parts = append(parts, command[start:m[0]])
+		start = m[1]
+	}
+	return append(parts, command[start:])
+}
+
+// leavesRoot reports whether any argument of command looks like a path outside the project root.
+func (p *Policy) leavesRoot(command string) bool {
+	for _, field := range strings.Fields(strings.Join(splitCommand(command), " ")) {
+		field = redirection.ReplaceAllString(field, "")
+		// Look at values of flags and env assignments too, e.g. --out=/tmp/x or DIR=../x.
+		if i := strings.IndexByte(field, '='); i >= 0 {
+			field = field[i+1:]
+		} else if strings.HasPrefix(field, "-") {
+			continue
+		}
+		field = strings.Trim(field, `"'`)
+		if !strings.HasPrefix(field, "/") && !strings.HasPrefix(field, "~") && !strings.Contains(field, "..") {
+			continue
+		}
+		if strings.HasPrefix(field, "~") {
+			return true
+		}
+		if _, inside := p.relative(field); !inside {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) ReadPath(path string) Action {
+	return p.pathAction(p.Read, path)
+}
+
+func (p *Policy) WritePath(path string) Action {
+	return p.pathAction(p.Write, path)
+}
+
+// MovePath decides on moving a file, which writes both the source and the destination.
+func (p *Policy) MovePath(from, to string) Action {
+	return stricter(p.WritePath(from), p.WritePath(to))
+}
+
+// SearchPath decides on a read-only code search rooted at path. It is configured separately
+// from Read so searches can be auto-approved without also exposing whole files.
+func (p *Policy) SearchPath(path string) Action {
+	return p.pathAction(p.Search, path)
+}
+
+// pathAction evaluates path rules against the project-relative path. Paths outside
+// the project root always need the user, unless they are denied.
+func (p *Policy) pathAction(rules []Rule, path string) Action {
+	rel, inside := p.relative(path)
+	action := evaluate(rules, rel, doublestar.Match)
+	if !inside {
+		return stricter(action, ForceAsk)
+	}
+	return action
+}
+
+func (p *Policy) relative(path string) (string, bool) {
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(p.root, path)
+	}
+	rel, err := filepath.Rel(p.root, filepath.Clean(abs))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(filepath.Clean(abs)), false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// evaluate returns the action of the first matching rule, or Ask if none matches.
+func evaluate(rules []Rule, subject string, glob func(pattern, subject string) (bool, error)) Action {
+	for _, rule := range rules {
+		if rule.matches(subject, glob) {
+			return rule.Action
+		}
+	}
+	return Ask
+}
+
+// evaluateStrict only reports deny rules matching subject, to be combined with a part-wise decision.
+func evaluateStrict(rules []Rule, subject string, glob func(pattern, subject string) (bool, error)) Action {
+	for _, rule := range rules {
+		if rule.Action == Deny && rule.matches(subject, glob) {
+			return Deny
+		}
+	}
+	return Allow
+}
+
+func (r Rule) matches(subject string, glob func(pattern, subject string) (bool, error)) bool {
+	if r.re != nil {
+		return r.re.MatchString(subject)
+	}
+	matched, err := glob(r.Pattern, subject)
+	return err == nil && matched
+}
+
+func matchCommandGlob(pattern, command string) (bool, error) {
+	var b strings.Builder
+	b.WriteString(`^`)
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString(`$`)
+	return regexp.MatchString(b.String(), strings.Join(strings.Fields(command), " "))
+}
+
+// Stricter returns the stricter of two verdicts.
+func Stricter(a, b Action) Action {
+	return stricter(a, b)
+}
+
+func stricter(a, b Action) Action {
+	rank := map[Action]int{Allow: 0, Ask: 1, ForceAsk: 2, Deny: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
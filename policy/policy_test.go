@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testPolicy(t *testing.T) *Policy {
+	t.Helper()
+	p := &Policy{
+		root: "/project",
+		Commands: []Rule{
+			{Pattern: "go test *", Action: Allow},
+			{Pattern: "go build *", Action: Allow},
+			{Pattern: "gofmt *", Action: Allow},
+			{Pattern: "git *", Action: Allow},
+			{Pattern: "echo *", Action: Allow},
+			{Pattern: "rm -rf *", Action: Deny},
+		},
+		Search: []Rule{
+			{Pattern: "**", Action: Allow},
+		},
+	}
+	for i := range p.Commands {
+		if err := p.Commands[i].compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"go test ./...", []string{"go test ./..."}},
+		{"go test ./... 2>&1", []string{"go test ./... 2>&1"}},
+		{"go build ./... &> build.log", []string{"go build ./... &> build.log"}},
+		{"go build ./... &>> build.log", []string{"go build ./... &>> build.log"}},
+		{"go vet ./... >&2", []string{"go vet ./... >&2"}},
+		{"go test ./... 2>&1 | tail", []string{"go test ./... 2>&1 ", " tail"}},
+		{"go build && go test", []string{"go build ", " go test"}},
+		{"a || b; c & d", []string{"a ", " b", " c ", " d"}},
+		{"a\nb", []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		if got := splitCommand(tt.command); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommand(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	p := testPolicy(t)
+	tests := []struct {
+		command string
+		want    Action
+	}{
+		{"go test ./...", Allow},
+		{"go test ./... 2>&1", Allow},
+		{"go build ./... && go test ./... 2>&1", Allow},
+		{"go test ./... > out.txt", Allow},
+		{"go test ./... | tee out.txt", Ask},
+		{"make", Ask},
+		{"echo $(cat x)", Ask},
+		{"rm -rf build", Deny},
+		{"go test ./... && rm -rf build", Deny},
+		{"git push origin main", ForceAsk},
+		{"git -c x=y push", ForceAsk},
+		{"git status", Allow},
+		{"gofmt -l /etc", ForceAsk},
+		{"gofmt -l ../other", ForceAsk},
+		{"gofmt -l ~/src", ForceAsk},
+		{"gofmt -l --out=/tmp/x .", ForceAsk},
+		{"gofmt x.go >/etc/hosts", ForceAsk},
+		{"gofmt x.go >>~/.bashrc", ForceAsk},
+		{"gofmt x.go 2>/tmp/x", ForceAsk},
+		{"gofmt x.go &>/tmp/x", ForceAsk},
+		{"gofmt x.go <../input", ForceAsk},
+		{"gofmt x.go > /project/out.txt", Allow},
+		{"gofmt /project/a.go", Allow},
+		{"rm -rf /", Deny},
+	}
+	for _, tt := range tests {
+		if got := p.Command(tt.command); got != tt.want {
+			t.Errorf("Command(%q) = %s, want %s", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestPathActions(t *testing.T) {
+	p := testPolicy(t)
+	tests := []struct {
+		name string
+		got  Action
+		want Action
+	}{
+		{"search inside", p.SearchPath("pkg"), Allow},
+		{"search outside", p.SearchPath("/etc"), ForceAsk},
+		{"search parent", p.SearchPath(".."), ForceAsk},
+		{"read without rules", p.ReadPath("a.go"), Ask},
+		{"read outside", p.ReadPath("../secret"), ForceAsk},
+		{"move outside", p.MovePath("a.go", "/tmp/a.go"), ForceAsk},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestStricter(t *testing.T) {
+	order := []Action{Allow, Ask, ForceAsk, Deny}
+	for i, a := range order {
+		for j, b := range order {
+			want := order[max(i, j)]
+			if got := Stricter(a, b); got != want {
+				t.Errorf("Stricter(%s, %s) = %s, want %s", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestRuleCompile(t *testing.T) {
+	tests := []struct {
+		rule    Rule
+		wantErr bool
+	}{
+		{Rule{Pattern: "go *", Action: Allow}, false},
+		{Rule{Regex: `^go\s`, Action: Deny}, false},
+		{Rule{Pattern: "go *", Action: ForceAsk}, true},
+		{Rule{Pattern: "go *", Regex: "go", Action: Ask}, true},
+		{Rule{Action: Ask}, true},
+		{Rule{Regex: "(", Action: Ask}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.rule.compile(); (err != nil) != tt.wantErr {
+			t.Errorf("compile(%+v) error = %v, want error %v", tt.rule, err, tt.wantErr)
+		}
+	}
+}
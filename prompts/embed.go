@@ -4,3 +4,9 @@ import _ "embed"
 
 //go:embed task_execution.txt
 var TaskExecutionSP string
+
+//go:embed pull_request.txt
+var PullRequestSP string
+
+//go:embed compaction.txt
+var CompactionSP string
@@ -0,0 +1,194 @@
+package redact
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// placeholderPrefix starts every replacement, so redacted text can be recognized later.
+const placeholderPrefix = "[REDACTED:"
+
+// Rule detects one kind of secret. If the regex has a capture group named "secret" only that group
+// is replaced, which keeps variable names and quotes around the value intact. Accept, if set, rejects
+// matches that are unlikely to be secrets.
+type Rule struct {
+	Name   string
+	Regex  *regexp.Regexp
+	Accept func(value string) bool
+}
+
+const secretNames = `[\w.-]*(?:token|secret|passw(?:or)?d|pwd|api[_-]?key|apikey|private[_-]?key|credentials?|auth[_-]?key)[\w.-]*`
+
+var builtinRules = []Rule{
+	{Name: "private_key", Regex: regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----[\s\S]*?(-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----|$)`)},
+	{Name: "aws_access_key", Regex: regexp.MustCompile(`\b(AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+	{Name: "aws_secret_key", Regex: regexp.MustCompile(`(?i)aws[\w.-]{0,20}(?:secret|private)[\w.-]{0,20}['"]?\s*(?:=|:|:=|=>)\s*['"]?(?P<secret>[A-Za-z0-9/+]{40})(?:[^A-Za-z0-9/+]|$)`)},
+	{Name: "jwt", Regex: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{Name: "github_token", Regex: regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{60,})\b`)},
+	{Name: "slack_token", Regex: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{Name: "api_key", Regex: regexp.MustCompile(`\bsk-(proj-|ant-)?[A-Za-z0-9_-]{20,}`)},
+	{Name: "url_credentials", Regex: regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@'"]+:(?P<secret>[^\s@/'"]+)@`)},
+	// Values of variables named like secrets: quoted literals anywhere, and unquoted values in
+	// env files, shell scripts and YAML. Code expressions such as cfg.Token are left alone.
+	// Line starts may carry the line number prefix added by get_file_contents.
+	{Name: "named_secret", Regex: regexp.MustCompile(`(?i)\b` + secretNames + `['"]?\s*(?:=|:|:=|=>)\s*(?:"(?P<secret>[^"\s]{6,})"|'(?P<secret2>[^'\s]{6,})')`), Accept: likelySecret},
+	{Name: "named_secret", Regex: regexp.MustCompile(`(?m)^\s*(?:\d+\t)?\s*(?:export\s+)?[A-Z0-9_]*(?:TOKEN|SECRET|PASSWORD|PASSWD|PWD|API_KEY|APIKEY|PRIVATE_KEY|CREDENTIALS?)[A-Z0-9_]*\s*=\s*(?P<secret>[^\s'"#$]{6,})`), Accept: likelySecret},
+	{Name: "named_secret", Regex: regexp.MustCompile(`(?im)^\s*(?:\d+\t)?\s*` + secretNames + `\s*:\s*(?P<secret>[^\s'"#{}\[\],&*|>!%@]\S{5,})\s*$`), Accept: likelySecret},
+}
+
+// assignment finds values assigned to names, used for the entropy check of otherwise unknown secrets.
+var assignment = regexp.MustCompile(`(?:=|:|:=|=>)\s*['"]?(?P<secret>[A-Za-z0-9+/=_\-]{20,})`)
+
+// checksum finds content hashes that look random but are public, such as go.sum "h1:" hashes, lockfile
+// "sha512-" integrity values and "sha256:" digests. The entropy check leaves them alone.
+var checksum = regexp.MustCompile(`\b(?:h1:|(?:sha1|sha256|sha384|sha512)[-:])[A-Za-z0-9+/=_\-]+`)
+
+const (
+	// minEntropy in bits per character separates random keys from identifiers and words.
+	minEntropy = 4.0
+	minLength  = 20
+)
+
+// Redactor replaces secrets in text with placeholders naming the kind of secret.
+type Redactor struct {
+	rules   []Rule
+	entropy bool
+}
+
+// Report counts redactions per kind of secret.
+type Report map[string]int
+
+func (r Report) Total() int {
+	total := 0
+	for _, n := range r {
+		total += n
+	}
+	return total
+}
+
+func (r Report) String() string {
+	kinds := make([]string, 0, len(r))
+	for kind := range r {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		parts = append(parts, fmt.Sprintf("%s x%d", kind, r[kind]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// New returns a redactor with the built-in rules followed by custom ones. With entropy set, long random
+// looking values assigned to any name are redacted as well.
+func New(custom []Rule, entropy bool) *Redactor {
+	return &Redactor{rules: append(append([]Rule(nil), builtinRules...), custom...), entropy: entropy}
+}
+
+// Redact returns text with all detected secrets replaced and a report of what was replaced.
+func (r *Redactor) Redact(text string) (string, Report) {
+	report := Report{}
+	for _, rule := range r.rules {
+		text = replace(text, rule, report, nil)
+	}
+	if r.entropy {
+		text = replace(text, Rule{Name: "high_entropy", Regex: assignment, Accept: random}, report, checksum.FindAllStringIndex(text, -1))
+	}
+	return text, report
+}
+
+// Placeholders counts the redaction placeholders in text.
+func Placeholders(text string) int {
+	return strings.Count(text, placeholderPrefix)
+}
+
+// replace replaces the matches of rule in text, except for those inside one of the keep ranges.
+func replace(text string, rule Rule, report Report, keep [][]int) string {
+	matches := rule.Regex.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var groups []int
+	for i, name := range rule.Regex.SubexpNames() {
+		if strings.HasPrefix(name, "secret") {
+			groups = append(groups, i)
+		}
+	}
+
+	placeholder := placeholderPrefix + rule.Name + "]"
+	var out strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		for _, g := range groups {
+			if m[2*g] >= 0 {
+				start, end = m[2*g], m[2*g+1]
+				break
+			}
+		}
+		if start < last || inside(start, end, keep) {
+			continue
+		}
+		value := text[start:end]
+		if strings.HasPrefix(value, placeholderPrefix) || (rule.Accept != nil && !rule.Accept(value)) {
+			continue
+		}
+		out.WriteString(text[last:start])
+		out.WriteString(placeholder)
+		last = end
+		report[rule.Name]++
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+func inside(start, end int, ranges [][]int) bool {
+	for _, r := range ranges {
+		if start >= r[0] && end <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// likelySecret rejects short words and placeholders such as "changeme" or "Authorization" by requiring
+// either some length or a mix of letters with digits or symbols.
+func likelySecret(value string) bool {
+	// Placeholders and template references, e.g. ${DB_PASSWORD} or {{ .Values.token }}.
+	if strings.Contains(value, placeholderPrefix) || strings.Contains(value, "${") || strings.Contains(value, "{{") {
+		return false
+	}
+	if len(value) >= 16 {
+		return true
+	}
+	hasLetter := strings.IndexFunc(value, unicode.IsLetter) >= 0
+	hasOther := strings.IndexFunc(value, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0
+	return len(value) >= 8 && hasLetter && hasOther
+}
+
+// random accepts long values mixing letters and digits with a high character entropy.
+func random(value string) bool {
+	hasLetter := strings.IndexFunc(value, unicode.IsLetter) >= 0
+	hasDigit := strings.IndexFunc(value, unicode.IsDigit) >= 0
+	return len(value) >= minLength && hasLetter && hasDigit && entropy(value) >= minEntropy
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, c := range s {
+		counts[c]++
+	}
+	var h float64
+	n := float64(len(s))
+	for _, count := range counts {
+		p := float64(count) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,52 @@
+package sandbox
+
+import (
+	"fmt"
+)
+
+// cappedBuffer keeps the first and last max/2 bytes written to it and counts what was dropped in between.
+type cappedBuffer struct {
+	max   int
+	head  []byte
+	tail  []byte
+	total int
+}
+
+func newCappedBuffer(max int) *cappedBuffer {
+	return &cappedBuffer{max: max}
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+	if b.max <= 0 {
+		b.head = append(b.head, p...)
+		return len(p), nil
+	}
+
+	headLimit := b.max / 2
+	rest := p
+	if room := headLimit - len(b.head); room > 0 {
+		n := min(room, len(rest))
+		b.head = append(b.head, rest[:n]...)
+		rest = rest[n:]
+	}
+
+	tailLimit := b.max - headLimit
+	b.tail = append(b.tail, rest...)
+	if len(b.tail) > tailLimit {
+		b.tail = append(b.tail[:0], b.tail[len(b.tail)-tailLimit:]...)
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) Truncated() bool {
+	return b.max > 0 && b.total > b.max
+}
+
+func (b *cappedBuffer) String() string {
+	if !b.Truncated() {
+		return string(b.head) + string(b.tail)
+	}
+	dropped := b.total - len(b.head) - len(b.tail)
+	return fmt.Sprintf("%s\n[... %d bytes truncated ...]\n%s", b.head, dropped, b.tail)
+}
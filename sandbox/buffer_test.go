@@ -0,0 +1,50 @@
+package sandbox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	tests := []struct {
+		name      string
+		max       int
+		writes    []string
+		want      string
+		truncated bool
+	}{
+		{name: "within the cap", max: 10, writes: []string{"abc", "def"}, want: "abcdef"},
+		{name: "exactly the cap", max: 6, writes: []string{"abcdef"}, want: "abcdef"},
+		{name: "no cap", max: 0, writes: []string{strings.Repeat("x", 100)}, want: strings.Repeat("x", 100)},
+		{
+			name:      "keeps head and tail",
+			max:       6,
+			writes:    []string{"abcdefghij"},
+			want:      "abc\n[... 4 bytes truncated ...]\nhij",
+			truncated: true,
+		},
+		{
+			name:      "tail follows many small writes",
+			max:       4,
+			writes:    []string{"a", "b", "c", "d", "e", "f", "g"},
+			want:      "ab\n[... 3 bytes truncated ...]\nfg",
+			truncated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newCappedBuffer(tt.max)
+			for _, w := range tt.writes {
+				if n, err := b.Write([]byte(w)); err != nil || n != len(w) {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if b.Truncated() != tt.truncated {
+				t.Errorf("Truncated() = %v, want %v", b.Truncated(), tt.truncated)
+			}
+		})
+	}
+}
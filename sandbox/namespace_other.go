@@ -0,0 +1,12 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func isolateNamespace(_ *exec.Cmd) error {
+	return errors.New("sandbox mode namespace is only supported on Linux")
+}
@@ -0,0 +1,53 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunTimeoutKillsProcessGroup(t *testing.T) {
+	root := t.TempDir()
+	policy := Policy{Root: root, Timeout: 200 * time.Millisecond}
+
+	// The backgrounded child keeps the output pipes open and would leave a marker if it survived.
+	command := "(sleep 0.5; touch survived) & sleep 30"
+	start := time.Now()
+	result, err := Run(context.Background(), policy, command, "", io.Discard, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.TimedOut {
+		t.Errorf("result = %+v, want a timeout", result)
+	}
+	if elapsed := time.Since(start); elapsed >= waitDelay {
+		t.Errorf("Run took %s, want the process group killed at the timeout", elapsed)
+	}
+
+	time.Sleep(time.Second)
+	if _, err := os.Stat(filepath.Join(root, "survived")); err == nil {
+		t.Error("the background child survived the timeout")
+	}
+}
+
+func TestRunCancelKillsProcessGroup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	result, err := Run(ctx, Policy{Root: t.TempDir()}, "sleep 30 & sleep 30", "", io.Discard, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TimedOut || result.ExitCode == 0 {
+		t.Errorf("result = %+v, want a killed command that did not time out", result)
+	}
+	if elapsed := time.Since(start); elapsed >= waitDelay {
+		t.Errorf("Run took %s after cancelling", elapsed)
+	}
+}
@@ -0,0 +1,188 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	ModeNone      = "none"
+	ModeNamespace = "namespace"
+	ModeBwrap     = "bwrap"
+)
+
+// waitDelay bounds how long Run waits for output pipes after the process is killed,
+// in case a grandchild outside the process group keeps them open.
+const waitDelay = 2 * time.Second
+
+// Policy describes how shell commands requested by the model are executed.
+type Policy struct {
+	// Root is the project root; relative working directories are resolved against it.
+	Root string
+	// Timeout kills the whole process group after the given duration; zero disables it.
+	Timeout time.Duration
+	// MaxOutput caps the captured size of stdout and stderr each; zero disables the cap.
+	MaxOutput int
+	// Jail rejects working directories outside of Root.
+	Jail bool
+	// EnvAllowlist restricts the environment to variables whose names match one of the glob patterns.
+	// An empty list passes the full environment through.
+	EnvAllowlist []string
+	// Mode selects process isolation: none, namespace (Linux user+network namespace, no network)
+	// or bwrap (bubblewrap with read-only host filesystem, writable Root and no network).
+	Mode string
+}
+
+type Result struct {
+	ExitCode  int
+	Stdout    string
+	Stderr    string
+	TimedOut  bool
+	Truncated bool
+	Duration  time.Duration
+}
+
+// Run executes command with sh -c in dir according to policy, streaming its output to stdout and stderr
+// while capturing a possibly truncated copy for the result. Cancelling ctx kills the process group.
+func Run(ctx context.Context, policy Policy, command, dir string, stdout, stderr io.Writer) (*Result, error) {
+	workDir, err := policy.resolveDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		defer cancel()
+	}
+
+	cmd, err := policy.command(ctx, command, workDir)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Env = policy.environment()
+	cmd.WaitDelay = waitDelay
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	setProcessGroup(cmd)
+
+	stdoutBuf := newCappedBuffer(policy.MaxOutput)
+	stderrBuf := newCappedBuffer(policy.MaxOutput)
+	cmd.Stdout = io.MultiWriter(stdout, stdoutBuf)
+	cmd.Stderr = io.MultiWriter(stderr, stderrBuf)
+
+	start := time.Now()
+	runErr := cmd.Run()
+	result := &Result{
+		Stdout:    stdoutBuf.String(),
+		Stderr:    stderrBuf.String(),
+		Truncated: stdoutBuf.Truncated() || stderrBuf.Truncated(),
+		Duration:  time.Since(start),
+		TimedOut:  errors.Is(ctx.Err(), context.DeadlineExceeded),
+	}
+
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(runErr, &exitErr) {
+			return nil, runErr
+		}
+		result.ExitCode = exitErr.ExitCode()
+	}
+	return result, nil
+}
+
+func (p Policy) resolveDir(dir string) (string, error) {
+	root, err := filepath.Abs(p.Root)
+	if err != nil {
+		return "", err
+	}
+	if dir == "" {
+		return root, nil
+	}
+
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid working directory: %w", err)
+	}
+
+	if p.Jail {
+		realRoot, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(realRoot, resolved)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("working directory %s is outside of the project root", dir)
+		}
+	}
+	return resolved, nil
+}
+
+func (p Policy) command(ctx context.Context, command, dir string) (*exec.Cmd, error) {
+	switch p.Mode {
+	case "", ModeNone:
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Dir = dir
+		return cmd, nil
+	case ModeNamespace:
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Dir = dir
+		if err := isolateNamespace(cmd); err != nil {
+			return nil, err
+		}
+		return cmd, nil
+	case ModeBwrap:
+		bwrap, err := exec.LookPath("bwrap")
+		if err != nil {
+			return nil, errors.New("sandbox mode bwrap requires bubblewrap (bwrap) to be installed")
+		}
+		root, err := filepath.Abs(p.Root)
+		if err != nil {
+			return nil, err
+		}
+		args := []string{
+			"--die-with-parent",
+			"--unshare-net",
+			"--ro-bind", "/", "/",
+			"--dev", "/dev",
+			"--proc", "/proc",
+			"--tmpfs", "/tmp",
+			"--bind", root, root,
+			"--chdir", dir,
+			"--", "sh", "-c", command,
+		}
+		return exec.CommandContext(ctx, bwrap, args...), nil
+	default:
+		return nil, fmt.Errorf("unknown sandbox mode: %s", p.Mode)
+	}
+}
+
+func (p Policy) environment() []string {
+	if len(p.EnvAllowlist) == 0 {
+		return os.Environ()
+	}
+
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		for _, pattern := range p.EnvAllowlist {
+			if matched, _ := path.Match(pattern, name); matched {
+				env = append(env, kv)
+				break
+			}
+		}
+	}
+	return env
+}
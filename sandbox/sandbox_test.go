@@ -0,0 +1,112 @@
+package sandbox
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResolveDirJail(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := Policy{Root: root, Jail: true}
+	tests := []struct {
+		dir     string
+		want    string
+		wantErr bool
+	}{
+		{dir: "", want: root},
+		{dir: "sub", want: filepath.Join(realRoot, "sub")},
+		{dir: filepath.Join(root, "sub"), want: filepath.Join(realRoot, "sub")},
+		{dir: "sub/..", want: realRoot},
+		{dir: "..", wantErr: true},
+		{dir: "sub/../..", wantErr: true},
+		{dir: outside, wantErr: true},
+		{dir: "link", wantErr: true},
+		{dir: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := policy.resolveDir(tt.dir)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("resolveDir(%q) = %q, %v, want %q (error %v)", tt.dir, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	if _, err := (Policy{Root: root}).resolveDir(outside); err != nil {
+		t.Errorf("resolveDir without jail rejected %s: %v", outside, err)
+	}
+}
+
+func TestEnvironmentAllowlist(t *testing.T) {
+	t.Setenv("DWIGHT_TEST_KEEP", "1")
+	t.Setenv("DWIGHT_TEST_SECRET", "2")
+	t.Setenv("PATH", "/usr/bin:/bin")
+
+	env := Policy{EnvAllowlist: []string{"PATH", "DWIGHT_TEST_K*"}}.environment()
+	if !slices.Contains(env, "PATH=/usr/bin:/bin") || !slices.Contains(env, "DWIGHT_TEST_KEEP=1") {
+		t.Errorf("environment() = %v, want PATH and DWIGHT_TEST_KEEP", env)
+	}
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if name != "PATH" && name != "DWIGHT_TEST_KEEP" {
+			t.Errorf("environment() passed %s, which is not allowed", name)
+		}
+	}
+
+	if env := (Policy{}).environment(); !slices.Contains(env, "DWIGHT_TEST_SECRET=2") {
+		t.Error("environment() without an allowlist dropped variables")
+	}
+}
+
+func TestRunEnvironmentAllowlist(t *testing.T) {
+	t.Setenv("DWIGHT_TEST_SECRET", "hidden")
+	policy := Policy{Root: t.TempDir(), EnvAllowlist: []string{"PATH"}}
+
+	result, err := Run(context.Background(), policy, `echo "secret=$DWIGHT_TEST_SECRET"`, "", io.Discard, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != "secret=\n" {
+		t.Errorf("stdout = %q, want the variable removed", result.Stdout)
+	}
+}
+
+func TestRunCapturesOutputAndExitCode(t *testing.T) {
+	var streamed strings.Builder
+	policy := Policy{Root: t.TempDir(), MaxOutput: 8}
+
+	result, err := Run(context.Background(), policy, "printf 0123456789abcdef; echo oops >&2; exit 3", "", &streamed, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 3 || result.Stderr != "oops\n" || result.TimedOut {
+		t.Errorf("result = %+v", result)
+	}
+	if !result.Truncated || result.Stdout != "0123\n[... 8 bytes truncated ...]\ncdef" {
+		t.Errorf("stdout = %q (truncated %v), want head and tail", result.Stdout, result.Truncated)
+	}
+	if streamed.String() != "0123456789abcdef" {
+		t.Errorf("streamed output = %q, want it complete", streamed.String())
+	}
+}
+
+func TestRunRejectsUnknownMode(t *testing.T) {
+	if _, err := Run(context.Background(), Policy{Root: t.TempDir(), Mode: "vm"}, "true", "", io.Discard, io.Discard); err == nil {
+		t.Error("Run accepted an unknown sandbox mode")
+	}
+}
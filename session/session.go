@@ -0,0 +1,234 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const Dir = ".dwight/sessions"
+
+const (
+	StatusRunning        = "running"
+	StatusCompleted      = "completed"
+	StatusPartial        = "partial"
+	StatusStopped        = "stopped"
+	StatusGaveUp         = "gave_up"
+	StatusFailed         = "failed"
+	StatusBudgetExceeded = "budget_exceeded"
+	StatusInputRequired  = "input_required"
+	StatusAborted        = "aborted"
+	StatusLimitReached   = "limit_reached"
+	StatusProviderError  = "provider_error"
+)
+
+// Kinds of file changes.
+const (
+	ChangeCreated  = "created"
+	ChangeModified = "modified"
+	ChangeDeleted  = "deleted"
+	ChangeMoved    = "moved"
+)
+
+type Session struct {
+	ID           string                         `json:"id"`
+	Task         string                         `json:"task"`
+	Status       string                         `json:"status"`
+	Error        string                         `json:"error,omitempty"`
+	CheckpointID string                         `json:"checkpoint_id,omitempty"`
+	Usage        Usage                          `json:"usage"`
+	Redactions   map[string]int                 `json:"redactions,omitempty"`
+	Changes      []FileChange                   `json:"changes,omitempty"`
+	Commands     []CommandRun                   `json:"commands,omitempty"`
+	Completion   *Completion                    `json:"completion,omitempty"`
+	CreatedAt    time.Time                      `json:"created_at"`
+	UpdatedAt    time.Time                      `json:"updated_at"`
+	Messages     []openai.ChatCompletionMessage `json:"messages"`
+}
+
+// Usage accumulates token usage and cost over all model requests of a session.
+type Usage struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CachedTokens     int     `json:"cached_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	CostKnown        bool    `json:"cost_known"`
+}
+
+// FileChange records a file written by a tool. From is the previous path of moved files.
+type FileChange struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+	From string `json:"from,omitempty"`
+}
+
+// CommandRun records a command that was executed on behalf of the model.
+type CommandRun struct {
+	Command  string `json:"command"`
+	ExitCode int    `json:"exit_code"`
+	TimedOut bool   `json:"timed_out,omitempty"`
+}
+
+// Outcomes the model can report in task_complete.
+const (
+	CompletionSuccess = "success"
+	CompletionPartial = "partial"
+	CompletionFailed  = "failed"
+)
+
+// Completion is what the model reported when it called task_complete.
+type Completion struct {
+	Status       string   `json:"status"`
+	Summary      string   `json:"summary"`
+	FilesChanged []string `json:"files_changed"`
+	Verification string   `json:"verification"`
+	OpenIssues   []string `json:"open_issues"`
+	// Verified is set when the configured verification command passed.
+	Verified bool `json:"verified,omitempty"`
+}
+
+func (u Usage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+func New(task string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:        newID(now),
+		Task:      task,
+		Status:    StatusRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Save writes the session atomically so a crash mid-write never leaves a truncated file behind.
+func (s *Session) Save() error {
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return err
+	}
+
+	s.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(Dir, s.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path(s.ID))
+}
+
+// PendingToolCalls returns tool calls of the last assistant message that have no tool result yet,
+// i.e. the calls that were in flight when the session was interrupted.
+func (s *Session) PendingToolCalls() []openai.ToolCall {
+	last := -1
+	for i := len(s.Messages) - 1; i >= 0; i-- {
+		if s.Messages[i].Role == openai.ChatMessageRoleAssistant {
+			last = i
+			break
+		}
+	}
+	if last < 0 {
+		return nil
+	}
+
+	answered := make(map[string]bool)
+	for _, msg := range s.Messages[last+1:] {
+		if msg.Role == openai.ChatMessageRoleTool {
+			answered[msg.ToolCallID] = true
+		}
+	}
+
+	var pending []openai.ToolCall
+	for _, call := range s.Messages[last].ToolCalls {
+		if !answered[call.ID] {
+			pending = append(pending, call)
+		}
+	}
+	return pending
+}
+
+func Load(id string) (*Session, error) {
+	data, err := os.ReadFile(path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("session %s not found", id)
+		}
+		return nil, err
+	}
+
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("error parsing session %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+// List returns all stored sessions, most recently updated first.
+func List() ([]*Session, error) {
+	entries, err := os.ReadDir(Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var sessions []*Session
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		s, err := Load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+func Remove(id string) error {
+	if err := os.Remove(path(id)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("session %s not found", id)
+		}
+		return err
+	}
+	return nil
+}
+
+func path(id string) string {
+	return filepath.Join(Dir, filepath.Base(id)+".json")
+}
+
+func newID(now time.Time) string {
+	suffix := make([]byte, 2)
+	_, _ = rand.Read(suffix)
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
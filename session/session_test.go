@@ -0,0 +1,144 @@
+package session
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func toolCall(id, name string) openai.ToolCall {
+	return openai.ToolCall{ID: id, Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: name, Arguments: "{}"}}
+}
+
+func TestSaveLoad(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	s := New("add a feature")
+	s.CheckpointID = "20240102-150405.000"
+	s.Usage = Usage{Requests: 2, PromptTokens: 100, CachedTokens: 40, CompletionTokens: 20, Cost: 0.5, CostKnown: true}
+	s.Changes = []FileChange{{Path: "b.go", Kind: ChangeMoved, From: "a.go"}}
+	s.Commands = []CommandRun{{Command: "go test ./...", ExitCode: 1}}
+	s.Completion = &Completion{Status: CompletionPartial, Summary: "half done", FilesChanged: []string{"b.go"}, OpenIssues: []string{"tests"}}
+	s.Messages = []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "task"},
+		{Role: openai.ChatMessageRoleAssistant, ToolCalls: []openai.ToolCall{toolCall("call_0", "move_files")}},
+		{Role: openai.ChatMessageRoleTool, ToolCallID: "call_0", Content: "a.go -> b.go: Moved"},
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.UpdatedAt.Equal(s.UpdatedAt) || !loaded.CreatedAt.Equal(s.CreatedAt) {
+		t.Errorf("timestamps = %v, %v, want %v, %v", loaded.CreatedAt, loaded.UpdatedAt, s.CreatedAt, s.UpdatedAt)
+	}
+	loaded.CreatedAt, loaded.UpdatedAt = s.CreatedAt, s.UpdatedAt
+	if !reflect.DeepEqual(loaded, s) {
+		t.Errorf("Load() = %+v, want %+v", loaded, s)
+	}
+
+	entries, err := os.ReadDir(Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != s.ID+".json" {
+		t.Errorf("session directory holds %v, want only %s.json", entries, s.ID)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	if _, err := Load("missing"); err == nil || err.Error() != "session missing not found" {
+		t.Errorf("Load(missing) error = %v", err)
+	}
+
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path("broken"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load("broken"); err == nil {
+		t.Error("Load(broken) succeeded, want a parse error")
+	}
+}
+
+func TestList(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	older, newer := New("first"), New("second")
+	older.ID, newer.ID = "older", "newer"
+	for _, s := range []*Session{newer, older} {
+		if err := s.Save(); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Saving again makes the first session the most recently updated one.
+	if err := newer.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "newer" || sessions[1].ID != "older" {
+		t.Errorf("List() = %+v, want newer, older", sessions)
+	}
+}
+
+func TestPendingToolCalls(t *testing.T) {
+	user := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: "task"}
+	assistant := func(calls ...openai.ToolCall) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, ToolCalls: calls}
+	}
+	result := func(id string) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleTool, ToolCallID: id, Content: "ok"}
+	}
+
+	tests := []struct {
+		name     string
+		messages []openai.ChatCompletionMessage
+		want     []string
+	}{
+		{name: "no assistant message", messages: []openai.ChatCompletionMessage{user}},
+		{name: "text answer", messages: []openai.ChatCompletionMessage{user, assistant()}},
+		{
+			name:     "all calls answered",
+			messages: []openai.ChatCompletionMessage{user, assistant(toolCall("a", "read"), toolCall("b", "read")), result("b"), result("a")},
+		},
+		{
+			name:     "interrupted mid-turn",
+			messages: []openai.ChatCompletionMessage{user, assistant(toolCall("a", "read"), toolCall("b", "write"), toolCall("c", "run")), result("a")},
+			want:     []string{"b", "c"},
+		},
+		{
+			// IDs are only unique per turn, results of an earlier turn do not answer the last one.
+			name: "results of earlier turns",
+			messages: []openai.ChatCompletionMessage{
+				user, assistant(toolCall("call_0", "read")), result("call_0"), assistant(toolCall("call_0", "write")),
+			},
+			want: []string{"call_0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Session{Messages: tt.messages}
+			var got []string
+			for _, call := range s.PendingToolCalls() {
+				got = append(got, call.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PendingToolCalls() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInputRequired stops a non-interactive run that would otherwise wait for the user.
+var ErrInputRequired = errors.New("user input required in non-interactive mode")
+
+const (
+	UnansweredAssume = "assume"
+	UnansweredFail   = "fail"
+)
+
+const assumptionAnswer = "No answer is available, this task runs non-interactively. Make a reasonable assumption, state it in your final summary, and continue."
+
+// Answers replies to questions of the model in non-interactive runs.
+type Answers struct {
+	rules      []answerRule
+	unanswered string
+}
+
+type answerRule struct {
+	Question string `yaml:"question"`
+	Answer   string `yaml:"answer"`
+
+	re *regexp.Regexp
+}
+
+// LoadAnswers reads an answers file, a YAML list of entries with a question regex and an answer.
+// The first rule matching a question wins. Questions without a matching rule are handled according to
+// unanswered: UnansweredAssume tells the model to make an assumption, UnansweredFail stops the run.
+// path may be empty to rely on unanswered alone.
+func LoadAnswers(path, unanswered string) (*Answers, error) {
+	if unanswered != UnansweredAssume && unanswered != UnansweredFail {
+		return nil, fmt.Errorf("unknown unanswered question handling %q, expected %s or %s", unanswered, UnansweredAssume, UnansweredFail)
+	}
+	a := &Answers{unanswered: unanswered}
+	if path == "" {
+		return a, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &a.rules); err != nil {
+		return nil, fmt.Errorf("error parsing answers %s: %w", path, err)
+	}
+	for i := range a.rules {
+		re, err := regexp.Compile(a.rules[i].Question)
+		if err != nil {
+			return nil, fmt.Errorf("invalid question pattern in answers %s: %w", path, err)
+		}
+		a.rules[i].re = re
+	}
+	return a, nil
+}
+
+// Answer returns the scripted answer to question, or false if there is none.
+func (a *Answers) Answer(question string) (string, bool) {
+	for _, rule := range a.rules {
+		if rule.re.MatchString(question) {
+			return rule.Answer, true
+		}
+	}
+	return "", false
+}
+
+// SetNonInteractive makes the executor never read stdin: questions are answered from answers and
+// confirmations the policy does not decide stop the run with ErrInputRequired, unless --yes approves them.
+func (e *Executor) SetNonInteractive(answers *Answers) {
+	e.answers = answers
+}
+
+func (e *Executor) interactive() bool {
+	return e.answers == nil
+}
+
+// requireInput records that the run cannot continue without the user. The tool call in progress is left
+// unanswered, so resuming the session interactively runs it again.
+func (e *Executor) requireInput(format string, args ...any) {
+	if e.inputRequired == nil {
+		e.inputRequired = fmt.Errorf("%w: %s", ErrInputRequired, fmt.Sprintf(format, args...))
+	}
+}
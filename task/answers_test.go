@@ -0,0 +1,123 @@
+package task
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rofleksey/dwight/api"
+	"github.com/rofleksey/dwight/session"
+	"github.com/sashabaranov/go-openai"
+)
+
+func writeAnswers(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "answers.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadAnswers(t *testing.T) {
+	path := writeAnswers(t, `
+- question: "(?i)which database"
+  answer: PostgreSQL
+- question: "(?i)database"
+  answer: never used, the first matching rule wins
+- question: "^Should I add tests"
+  answer: "yes"
+`)
+	answers, err := LoadAnswers(path, UnansweredFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		question string
+		answer   string
+		ok       bool
+	}{
+		{question: "Which database should I use?", answer: "PostgreSQL", ok: true},
+		{question: "Is the database shared?", answer: "never used, the first matching rule wins", ok: true},
+		{question: "Should I add tests?", answer: "yes", ok: true},
+		{question: "Where should I add tests?", ok: false},
+	}
+	for _, tt := range tests {
+		answer, ok := answers.Answer(tt.question)
+		if answer != tt.answer || ok != tt.ok {
+			t.Errorf("Answer(%q) = %q, %v, want %q, %v", tt.question, answer, ok, tt.answer, tt.ok)
+		}
+	}
+}
+
+func TestLoadAnswersErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		unanswered string
+		wantErr    string
+	}{
+		{name: "unknown unanswered handling", unanswered: "skip", wantErr: `unknown unanswered question handling "skip"`},
+		{name: "missing file", path: filepath.Join(t.TempDir(), "missing.yaml"), unanswered: UnansweredAssume, wantErr: "no such file"},
+		{name: "invalid yaml", path: writeAnswers(t, "question: [unclosed"), unanswered: UnansweredAssume, wantErr: "error parsing answers"},
+		{name: "invalid pattern", path: writeAnswers(t, "- question: \"(unclosed\"\n  answer: x\n"), unanswered: UnansweredAssume, wantErr: "invalid question pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadAnswers(tt.path, tt.unanswered)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadAnswers() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if answers, err := LoadAnswers("", UnansweredAssume); err != nil || answers.unanswered != UnansweredAssume {
+		t.Errorf("LoadAnswers without a file = %+v, %v", answers, err)
+	}
+}
+
+func TestExecuteAnswersQuestionsNonInteractively(t *testing.T) {
+	ask := func(question string) api.ScriptedResponse {
+		return api.ScriptedResponse{ToolCalls: []api.ScriptedToolCall{call("ask_question", map[string]string{"question": question})}}
+	}
+	tests := []struct {
+		name       string
+		unanswered string
+		question   string
+		want       string
+		wantErr    error
+	}{
+		{name: "scripted answer", unanswered: UnansweredFail, question: "Which database?", want: "Answer: PostgreSQL"},
+		{name: "assume", unanswered: UnansweredAssume, question: "Which port?", want: "Answer: " + assumptionAnswer},
+		{name: "fail", unanswered: UnansweredFail, question: "Which port?", wantErr: ErrInputRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupProject(t)
+			answers, err := LoadAnswers(writeAnswers(t, "- question: database\n  answer: PostgreSQL\n"), tt.unanswered)
+			if err != nil {
+				t.Fatal(err)
+			}
+			executor, provider, _ := newTestExecutor(t, testConfig(), ask(tt.question), complete(session.CompletionSuccess))
+			executor.SetNonInteractive(answers)
+
+			err = executor.Execute("set up the service")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Execute() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			messages := provider.Requests()[1].Messages
+			if result := messages[len(messages)-1]; result.Role != openai.ChatMessageRoleTool || result.Content != tt.want {
+				t.Errorf("answer sent to the model = %+v, want %q", result, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/rofleksey/dwight/event"
+	"github.com/rofleksey/dwight/sandbox"
+	"github.com/rofleksey/dwight/session"
+	"github.com/sashabaranov/go-openai"
+)
+
+// handleTaskComplete accepts the completion reported by the model. Completions claiming success or partial
+// success are only accepted once the configured verification command passes; its failures are sent back
+// so the model keeps working, until verify_max_attempts is reached.
+func (e *Executor) handleTaskComplete(toolCall openai.ToolCall, messages *[]openai.ChatCompletionMessage) error {
+	reply := func(content string) {
+		*messages = append(*messages, openai.ChatCompletionMessage{
+			Role:       openai.ChatMessageRoleTool,
+			Content:    content,
+			ToolCallID: toolCall.ID,
+		})
+	}
+
+	var completion session.Completion
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &completion); err != nil {
+		reply(fmt.Sprintf("Invalid arguments: %v. The task is not complete yet, call task_complete again with valid arguments.", err))
+		return nil
+	}
+	if err := validateCompletion(completion); err != nil {
+		reply(fmt.Sprintf("%v. The task is not complete yet, call task_complete again with valid arguments.", err))
+		return nil
+	}
+
+	if e.cfg.VerifyCommand != "" && completion.Status != session.CompletionFailed {
+		resp, passed := e.verify()
+		if !passed {
+			e.verifyFailures++
+			if e.verifyFailures < e.cfg.VerifyMaxAttempts {
+				resp["message"] = fmt.Sprintf("Verification failed, the task is not complete. Fix the problems and call task_complete again (attempt %d of %d).",
+					e.verifyFailures, e.cfg.VerifyMaxAttempts)
+				payload, _ := json.Marshal(resp)
+				reply(string(payload))
+				return nil
+			}
+			e.completionErr = fmt.Errorf("verification command failed %d time(s)", e.verifyFailures)
+		}
+		completion.Verified = passed
+	}
+
+	e.session.Completion = &completion
+	reply("Task completion acknowledged")
+	return nil
+}
+
+func validateCompletion(c session.Completion) error {
+	switch c.Status {
+	case session.CompletionSuccess, session.CompletionPartial, session.CompletionFailed:
+	default:
+		return fmt.Errorf("status must be one of %s, %s or %s", session.CompletionSuccess, session.CompletionPartial, session.CompletionFailed)
+	}
+	if strings.TrimSpace(c.Summary) == "" {
+		return fmt.Errorf("summary is required")
+	}
+	if strings.TrimSpace(c.Verification) == "" {
+		return fmt.Errorf("verification is required, describe how the changes were checked or state that they were not")
+	}
+	return nil
+}
+
+// verify runs the configured verification command and returns its result for the model.
+func (e *Executor) verify() (map[string]interface{}, bool) {
+	e.infof("Verifying: %s", e.cfg.VerifyCommand)
+	resp := map[string]interface{}{
+		"verification_command": e.cfg.VerifyCommand,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	result, err := sandbox.Run(ctx, e.commandPolicy(), e.cfg.VerifyCommand, "", event.Writer(sessionSink{e}, "stdout"), event.Writer(sessionSink{e}, "stderr"))
+	interrupted := ctx.Err() != nil
+	stop()
+
+	if err != nil {
+		e.warnf("Verification failed: %v", err)
+		resp["error"] = err.Error()
+		return resp, false
+	}
+	e.recordCommand(e.cfg.VerifyCommand, result.ExitCode, result.TimedOut)
+	resp["exit_code"] = result.ExitCode
+	resp["stdout"] = result.Stdout
+	resp["stderr"] = result.Stderr
+	if result.Truncated {
+		resp["output_truncated"] = true
+	}
+
+	switch {
+	case result.TimedOut:
+		e.warnf("Verification timed out after %s", e.cfg.CommandTimeout)
+		resp["timed_out"] = true
+	case interrupted:
+		e.warnf("Verification interrupted by user")
+		resp["interrupted"] = true
+	case result.ExitCode != 0:
+		e.warnf("Verification failed: exit code %d", result.ExitCode)
+	default:
+		e.infof("Verification passed")
+		return resp, true
+	}
+	return resp, false
+}
+
+// completionStatus maps the completion reported by the model to the final status of the session.
+func (e *Executor) completionStatus() (string, error) {
+	c := e.session.Completion
+	if e.completionErr != nil {
+		e.errorf("Task not completed: %v", e.completionErr)
+		return session.StatusFailed, e.completionErr
+	}
+
+	switch c.Status {
+	case session.CompletionSuccess:
+		e.infof("Task completed!")
+		return session.StatusCompleted, nil
+	case session.CompletionPartial:
+		e.warnf("Task partially completed")
+		return session.StatusPartial, nil
+	default:
+		e.errorf("Task failed")
+		return session.StatusGaveUp, fmt.Errorf("the model reported the task as failed")
+	}
+}
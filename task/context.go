@@ -0,0 +1,302 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/rofleksey/dwight/prompts"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// charsPerToken is a rough estimate that holds reasonably well for code and English text.
+	charsPerToken = 4
+	// messageOverheadTokens accounts for role markers and other per-message framing.
+	messageOverheadTokens = 4
+	// initialMessages is the system prompt plus the user message with project structure and task; never compacted.
+	initialMessages = 2
+	// recentShare is the part of the context window kept verbatim after compaction.
+	recentShare = 0.3
+	// staleOutputKeep is how many characters of an old tool output survive truncation (split between head and tail).
+	staleOutputKeep = 2000
+	// summaryInputMessageChars caps each message when rendering history for the summarizer.
+	summaryInputMessageChars = 2000
+
+	truncatedOutputNotice = "older tool output truncated to save context"
+)
+
+// contextStats calibrates the character based estimate with prompt token counts reported by the provider.
+type contextStats struct {
+	estimated int
+	actual    int
+}
+
+func (c contextStats) scale(estimate int) int {
+	if c.estimated == 0 || c.actual == 0 {
+		return estimate
+	}
+	return estimate * c.actual / c.estimated
+}
+
+func estimateMessageTokens(msg openai.ChatCompletionMessage) int {
+	chars := len(msg.Content)
+	for _, call := range msg.ToolCalls {
+		chars += len(call.Function.Name) + len(call.Function.Arguments)
+	}
+	return chars/charsPerToken + messageOverheadTokens
+}
+
+func estimateTokens(messages []openai.ChatCompletionMessage, tools []openai.Tool) int {
+	total := 0
+	for _, msg := range messages {
+		total += estimateMessageTokens(msg)
+	}
+	if data, err := json.Marshal(tools); err == nil {
+		total += len(data) / charsPerToken
+	}
+	return total
+}
+
+// manageContext keeps the conversation below the compaction threshold of the model's context window.
+// It applies progressively more lossy steps and stops as soon as the history fits:
+// eliding file reads superseded by later writes, truncating old tool outputs, and finally
+// replacing older turns with a model-written summary.
+func (e *Executor) manageContext(messages *[]openai.ChatCompletionMessage, tools []openai.Tool) error {
+	limit := int(float64(e.cfg.ContextWindow) * e.cfg.CompactAt)
+	fits := func() bool {
+		return e.contextStats.scale(estimateTokens(*messages, tools)) <= limit
+	}
+
+	if fits() {
+		return nil
+	}
+
+	if n := elideSupersededReads(*messages); n > 0 {
+		e.infof("Context: elided %d stale file read(s)", n)
+		if fits() {
+			return nil
+		}
+	}
+
+	recentStart := recentBoundary(*messages, int(float64(e.cfg.ContextWindow)*recentShare))
+	if n := truncateToolOutputs(*messages, recentStart); n > 0 {
+		e.infof("Context: truncated %d old tool output(s)", n)
+		if fits() {
+			return nil
+		}
+	}
+
+	if recentStart <= initialMessages {
+		return errors.New("conversation does not fit into the context window even after truncation")
+	}
+
+	e.infof("Context: summarizing %d earlier message(s)...", recentStart-initialMessages)
+	summary, err := e.summarize((*messages)[initialMessages:recentStart])
+	if err != nil {
+		return fmt.Errorf("error compacting conversation: %w", err)
+	}
+
+	compacted := make([]openai.ChatCompletionMessage, 0, initialMessages+1+len(*messages)-recentStart)
+	compacted = append(compacted, (*messages)[:initialMessages]...)
+	compacted = append(compacted, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: "Summary of your earlier work on this task (older messages were compacted):\n\n" + summary,
+	})
+	compacted = append(compacted, (*messages)[recentStart:]...)
+	*messages = compacted
+	e.contextStats = contextStats{}
+	e.saveSession()
+	return nil
+}
+
+// recentBoundary returns the index of the earliest assistant message such that it and everything after it
+// fits into budget tokens. Starting at an assistant message keeps tool calls together with their results.
+// The last assistant turn is always part of the recent messages, even when it alone exceeds the budget.
+func recentBoundary(messages []openai.ChatCompletionMessage, budget int) int {
+	boundary := initialMessages
+	for i := len(messages) - 1; i >= initialMessages; i-- {
+		if messages[i].Role == openai.ChatMessageRoleAssistant {
+			boundary = i
+			break
+		}
+	}
+
+	used := 0
+	for i := len(messages) - 1; i >= initialMessages; i-- {
+		used += estimateMessageTokens(messages[i])
+		if used > budget {
+			break
+		}
+		if messages[i].Role == openai.ChatMessageRoleAssistant && i < boundary {
+			boundary = i
+		}
+	}
+	return boundary
+}
+
+// elideSupersededReads replaces file contents in get_file_contents results with a notice when the same
+// file was written afterwards, since the model would otherwise rely on an outdated copy.
+func elideSupersededReads(messages []openai.ChatCompletionMessage) int {
+	tools := resultToolNames(messages)
+
+	writtenAfter := make(map[string]int)
+	for i, msg := range messages {
+		if msg.Role != openai.ChatMessageRoleTool {
+			continue
+		}
+		for _, path := range writtenPaths(tools[i], msg.Content) {
+			writtenAfter[filepath.Clean(path)] = i
+		}
+	}
+
+	elided := 0
+	for i, msg := range messages {
+		if msg.Role != openai.ChatMessageRoleTool || tools[i] != "get_file_contents" {
+			continue
+		}
+
+		var contents map[string]string
+		if err := json.Unmarshal([]byte(msg.Content), &contents); err != nil {
+			continue
+		}
+
+		changed := false
+		for key, content := range contents {
+			if last, ok := writtenAfter[filepath.Clean(readKeyPath(key))]; ok && last > i && !strings.HasPrefix(content, "[elided") {
+				contents[key] = "[elided: this file was modified later in the conversation, read it again if needed]"
+				changed = true
+				elided++
+			}
+		}
+		if changed {
+			if data, err := json.Marshal(contents); err == nil {
+				messages[i].Content = string(data)
+			}
+		}
+	}
+	return elided
+}
+
+// resultToolNames returns the name of the tool that produced each tool message, indexed like messages.
+func resultToolNames(messages []openai.ChatCompletionMessage) []string {
+	names := make([]string, len(messages))
+	// Tool call IDs are only unique within their assistant message, some providers number them per turn.
+	var calls map[string]string
+	for i, msg := range messages {
+		if msg.Role == openai.ChatMessageRoleAssistant {
+			calls = make(map[string]string, len(msg.ToolCalls))
+			for _, call := range msg.ToolCalls {
+				calls[call.ID] = call.Function.Name
+			}
+			continue
+		}
+		if msg.Role == openai.ChatMessageRoleTool {
+			names[i] = calls[msg.ToolCallID]
+		}
+	}
+	return names
+}
+
+// writtenPaths returns the files a write tool actually changed according to its result. Results hold one
+// "<path>: <status>" line per file (or "<from> -> <to>: <status>" for moves), and denied, failed or
+// skipped writes must not make earlier reads look outdated.
+func writtenPaths(tool, result string) []string {
+	switch tool {
+	case "modify_files", "edit_file", "delete_files", "move_files":
+	default:
+		return nil
+	}
+
+	var paths []string
+	for _, line := range strings.Split(result, "\n") {
+		target, status, ok := strings.Cut(line, ": ")
+		if !ok || !isWriteSuccess(status) {
+			continue
+		}
+		if tool == "move_files" {
+			if from, to, ok := strings.Cut(target, " -> "); ok {
+				paths = append(paths, from, to)
+			}
+			continue
+		}
+		paths = append(paths, target)
+	}
+	return paths
+}
+
+func isWriteSuccess(status string) bool {
+	for _, prefix := range []string{"Updated", "Partially updated", "Deleted", "Moved"} {
+		if strings.HasPrefix(status, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateToolOutputs shortens large tool results before index end to their head and tail.
+func truncateToolOutputs(messages []openai.ChatCompletionMessage, end int) int {
+	truncated := 0
+	for i := initialMessages; i < end && i < len(messages); i++ {
+		content := messages[i].Content
+		if messages[i].Role != openai.ChatMessageRoleTool || len(content) <= staleOutputKeep || strings.Contains(content, truncatedOutputNotice) {
+			continue
+		}
+		messages[i].Content = headTail(content, staleOutputKeep, truncatedOutputNotice)
+		truncated++
+	}
+	return truncated
+}
+
+func headTail(text string, keep int, reason string) string {
+	if len(text) <= keep {
+		return text
+	}
+	half := keep / 2
+	return fmt.Sprintf("%s\n[... %d characters elided: %s ...]\n%s", text[:half], len(text)-keep, reason, text[len(text)-half:])
+}
+
+func (e *Executor) summarize(history []openai.ChatCompletionMessage) (string, error) {
+	var transcript strings.Builder
+	maxChars := e.cfg.ContextWindow * charsPerToken / 2
+	for _, msg := range history {
+		if transcript.Len() > maxChars {
+			transcript.WriteString("[... remaining history omitted ...]\n")
+			break
+		}
+		fmt.Fprintf(&transcript, "### %s\n", msg.Role)
+		if msg.Content != "" {
+			transcript.WriteString(headTail(msg.Content, summaryInputMessageChars, "truncated"))
+			transcript.WriteString("\n")
+		}
+		for _, call := range msg.ToolCalls {
+			fmt.Fprintf(&transcript, "-> %s(%s)\n", call.Function.Name, headTail(call.Function.Arguments, summaryInputMessageChars, "truncated"))
+		}
+	}
+
+	response, err := e.client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
+		Model: e.cfg.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: prompts.CompactionSP,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf("Task: %s\n\nConversation to summarize:\n%s", e.session.Task, transcript.String()),
+			},
+		},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	e.recordUsage(response.Usage)
+
+	if len(response.Choices) == 0 || strings.TrimSpace(response.Choices[0].Message.Content) == "" {
+		return "", errors.New("model returned an empty summary")
+	}
+	return strings.TrimSpace(response.Choices[0].Message.Content), nil
+}
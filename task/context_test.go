@@ -0,0 +1,145 @@
+package task
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func assistantCall(id, name, arguments string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleAssistant,
+		ToolCalls: []openai.ToolCall{{
+			ID:       id,
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: name, Arguments: arguments},
+		}},
+	}
+}
+
+func toolResult(id, content string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleTool, ToolCallID: id, Content: content}
+}
+
+func TestElideSupersededReads(t *testing.T) {
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "system"},
+		{Role: openai.ChatMessageRoleUser, Content: "task"},
+		// Providers such as Ollama number tool calls per turn, so call_0 is reused below.
+		assistantCall("call_0", "get_file_contents", `{"files":[{"path":"./a.go"}]}`),
+		toolResult("call_0", `{"./a.go":"package a"}`),
+		assistantCall("call_0", "get_file_contents", `{"files":[{"path":"b.go"}]}`),
+		toolResult("call_0", `{"b.go":"package b"}`),
+		assistantCall("call_0", "get_file_contents", `{"files":[{"path":"c.go"}]}`),
+		toolResult("call_0", `{"c.go":"package c"}`),
+		assistantCall("call_0", "edit_file", `{"file_path":"a.go"}`),
+		toolResult("call_0", "a.go: Updated (1 edits applied)"),
+		// Only writes that succeeded according to their results supersede reads.
+		assistantCall("call_0", "modify_files", `{"files":[{"file_path":"b.go"},{"file_path":"c.go"}]}`),
+		toolResult("call_0", "b.go: ERROR: Writing this file is denied by policy\nc.go: Skipped"),
+		assistantCall("call_0", "move_files", `{"moves":[{"from":"b.go","to":"d.go"}]}`),
+		toolResult("call_0", "b.go -> d.go: ERROR: rename failed"),
+	}
+
+	if got := elideSupersededReads(messages); got != 1 {
+		t.Errorf("elided %d reads, want 1", got)
+	}
+	if !strings.Contains(messages[3].Content, "[elided") {
+		t.Errorf("read of ./a.go before the edit was kept: %s", messages[3].Content)
+	}
+	if messages[5].Content != `{"b.go":"package b"}` {
+		t.Errorf("read of b.go was changed although writing it failed: %s", messages[5].Content)
+	}
+	if messages[7].Content != `{"c.go":"package c"}` {
+		t.Errorf("read of c.go was changed although writing it was skipped: %s", messages[7].Content)
+	}
+}
+
+func TestWrittenPaths(t *testing.T) {
+	tests := []struct {
+		tool   string
+		result string
+		want   []string
+	}{
+		{tool: "modify_files", result: "a.go: Updated\nb.go: Partially updated, 1 of 2 hunks applied\nc.go: Skipped\nd.go: ERROR: denied", want: []string{"a.go", "b.go"}},
+		{tool: "edit_file", result: "a.go: No changes"},
+		{tool: "delete_files", result: "a.go: Deleted\nb.go: ERROR: no such file", want: []string{"a.go"}},
+		{tool: "move_files", result: "a.go -> b.go: Moved\nc.go -> d.go: Skipped", want: []string{"a.go", "b.go"}},
+		{tool: "get_file_contents", result: "a.go: Updated"},
+	}
+	for _, tt := range tests {
+		if got := writtenPaths(tt.tool, tt.result); !slices.Equal(got, tt.want) {
+			t.Errorf("writtenPaths(%s, %q) = %q, want %q", tt.tool, tt.result, got, tt.want)
+		}
+	}
+}
+
+func TestRecentBoundary(t *testing.T) {
+	large := strings.Repeat("x", 4000)
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "system"},
+		{Role: openai.ChatMessageRoleUser, Content: "task"},
+		assistantCall("call_0", "run_command", `{}`),
+		toolResult("call_0", "ok"),
+		assistantCall("call_0", "run_command", `{}`),
+		toolResult("call_0", "ok"),
+		assistantCall("call_0", "get_file_contents", `{}`),
+		toolResult("call_0", large),
+	}
+
+	tests := []struct {
+		name   string
+		budget int
+		want   int
+	}{
+		{name: "everything fits", budget: 100000, want: 2},
+		{name: "only the last turns fit", budget: 1030, want: 4},
+		// The last turn alone exceeds the budget, but its tool results must stay intact.
+		{name: "last turn too large", budget: 10, want: 6},
+	}
+	for _, tt := range tests {
+		if got := recentBoundary(messages, tt.budget); got != tt.want {
+			t.Errorf("%s: recentBoundary() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	if got := recentBoundary(messages[:initialMessages], 10); got != initialMessages {
+		t.Errorf("recentBoundary() without assistant turns = %d, want %d", got, initialMessages)
+	}
+}
+
+func TestTruncateToolOutputs(t *testing.T) {
+	large := strings.Repeat("a", staleOutputKeep) + strings.Repeat("b", staleOutputKeep)
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: large},
+		{Role: openai.ChatMessageRoleUser, Content: large},
+		assistantCall("call_0", "run_command", `{}`),
+		toolResult("call_0", large),
+		toolResult("call_1", "short"),
+		{Role: openai.ChatMessageRoleUser, Content: large},
+		assistantCall("call_0", "run_command", `{}`),
+		toolResult("call_0", large),
+	}
+
+	end := recentBoundary(messages, 10)
+	if got := truncateToolOutputs(messages, end); got != 1 {
+		t.Errorf("truncated %d outputs, want 1", got)
+	}
+	if got := messages[3].Content; len(got) >= len(large) || !strings.HasPrefix(got, "aaa") || !strings.HasSuffix(got, "bbb") || !strings.Contains(got, truncatedOutputNotice) {
+		t.Errorf("old tool output was not reduced to its head and tail: %.100s", got)
+	}
+	for _, i := range []int{0, 1, 5, 7} {
+		if messages[i].Content != large {
+			t.Errorf("message %d (%s) was truncated", i, messages[i].Role)
+		}
+	}
+	if messages[4].Content != "short" {
+		t.Errorf("short output was changed: %s", messages[4].Content)
+	}
+
+	if got := truncateToolOutputs(messages, end); got != 0 {
+		t.Errorf("truncated %d outputs again, want outputs truncated once to be kept", got)
+	}
+}
@@ -0,0 +1,113 @@
+package task
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/rofleksey/dwight/event"
+	"github.com/rofleksey/dwight/policy"
+	"github.com/rofleksey/dwight/session"
+	"github.com/rofleksey/dwight/util"
+)
+
+func (e *Executor) emit(ev event.Event) {
+	if e.session != nil {
+		ev.Session = e.session.ID
+	}
+	e.events.Emit(ev)
+}
+
+// sessionSink lets writers such as event.Writer emit through the executor, so events carry the session.
+type sessionSink struct {
+	e *Executor
+}
+
+func (s sessionSink) Emit(ev event.Event) {
+	s.e.emit(ev)
+}
+
+func (e *Executor) infof(format string, args ...any) {
+	e.emit(event.Info(format, args...))
+}
+
+func (e *Executor) warnf(format string, args ...any) {
+	e.emit(event.Warning(format, args...))
+}
+
+func (e *Executor) errorf(format string, args ...any) {
+	e.emit(event.Error(format, args...))
+}
+
+// showDiff emits the change of a file from oldContent to newContent.
+func (e *Executor) showDiff(path, heading, oldContent, newContent string) error {
+	diff, err := util.UnifiedDiff(oldContent, newContent, filepath.ToSlash(path))
+	if err != nil {
+		return err
+	}
+	e.emit(event.Event{Type: event.Diff, Path: path, Text: heading, Diff: diff})
+	return nil
+}
+
+// confirm asks the user unless the policy already decided on the action.
+func (e *Executor) confirm(action policy.Action, prompt string) bool {
+	switch action {
+	case policy.Allow:
+		e.infof("%s (allowed by policy)", prompt)
+		return true
+	case policy.Deny:
+		e.infof("%s (denied by policy)", prompt)
+		return false
+	case policy.ForceAsk:
+		return e.confirmAction(prompt, true)
+	default:
+		return e.confirmAction(prompt, false)
+	}
+}
+
+// confirmAction asks the user a yes/no question, answering yes right away with --yes unless forced.
+func (e *Executor) confirmAction(prompt string, forced bool) bool {
+	if util.AutoConfirm() && !forced {
+		e.emit(event.Event{Type: event.ConfirmationNeeded, Prompt: prompt, Answer: "y"})
+		return true
+	}
+	if !e.interactive() {
+		e.emit(event.Event{Type: event.ConfirmationNeeded, Prompt: prompt, Answer: "n"})
+		e.requireInput("confirmation needed: %s", prompt)
+		return false
+	}
+	e.emit(event.Event{Type: event.ConfirmationNeeded, Prompt: prompt})
+	return strings.ToLower(util.ReadLine()) == "y"
+}
+
+// choose asks the user to pick one of choices by its first letter and returns that letter, asking again
+// on unknown input. It returns an empty string when stdin is closed.
+func (e *Executor) choose(prompt string, choices []string) string {
+	for {
+		if !e.interactive() {
+			e.emit(event.Event{Type: event.ConfirmationNeeded, Prompt: prompt, Choices: choices, Answer: "q"})
+			e.requireInput("choice needed: %s", prompt)
+			return ""
+		}
+		e.emit(event.Event{Type: event.ConfirmationNeeded, Prompt: prompt, Choices: choices})
+		response, ok := util.ReadLineOK()
+		response = strings.ToLower(response)
+		for _, choice := range choices {
+			if response != "" && strings.HasPrefix(choice, response[:1]) {
+				return choice[:1]
+			}
+		}
+		if !ok {
+			return ""
+		}
+	}
+}
+
+func usageStats(usage session.Usage) *event.UsageStats {
+	return &event.UsageStats{
+		PromptTokens:     usage.PromptTokens,
+		CachedTokens:     usage.CachedTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             usage.Cost,
+		CostKnown:        usage.CostKnown,
+	}
+}
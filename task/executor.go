@@ -3,74 +3,236 @@ package task
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/rofleksey/dwight/api"
+	"github.com/rofleksey/dwight/checkpoint"
 	"github.com/rofleksey/dwight/config"
+	"github.com/rofleksey/dwight/event"
+	"github.com/rofleksey/dwight/policy"
 	"github.com/rofleksey/dwight/prompts"
+	"github.com/rofleksey/dwight/redact"
+	"github.com/rofleksey/dwight/session"
+	"github.com/rofleksey/dwight/util/ignore"
 	"github.com/sashabaranov/go-openai"
 )
 
+// ErrAborted is returned when the user interrupts a model request.
+var ErrAborted = errors.New("AI request interrupted by user")
+
 type Executor struct {
-	client *api.OpenAIClient
+	client api.Provider
 	cfg    *config.Config
+	policy *policy.Policy
+	events event.Sink
+
+	redactor *redact.Redactor
+	// ignored and writeIgnored cache the ignore matchers between tool calls, see ignoreMatcher.
+	ignored      *ignore.Matcher
+	writeIgnored *ignore.Matcher
+
+	// answers is set in non-interactive runs, see SetNonInteractive.
+	answers       *Answers
+	inputRequired error
+
+	session           *session.Session
+	checkpointCreated bool
+	verifyFailures    int
+	completionErr     error
+	progress          progress
+	contextStats      contextStats
 }
 
-func NewExecutor(client *api.OpenAIClient, cfg *config.Config) *Executor {
+func NewExecutor(client api.Provider, cfg *config.Config, pol *policy.Policy, events event.Sink) *Executor {
 	return &Executor{
 		client: client,
 		cfg:    cfg,
+		policy: pol,
+		events: events,
+
+		redactor: newRedactor(cfg),
 	}
 }
 
 func (e *Executor) Execute(task string) error {
-	structure, err := e.getProjectStructure()
+	structure, err := e.getProjectStructure(task)
 	if err != nil {
 		return err
 	}
 
+	e.session = session.New(task)
+	structure = e.redactText(structure, "the project structure")
+	e.session.Messages = e.createInitialMessages(structure, task)
+	e.checkpointCreated = false
+	e.verifyFailures, e.completionErr = 0, nil
+	e.resetProgress()
+	e.saveSession()
+
+	e.infof("Session %s (continue with 'dwight resume %s' if interrupted)", e.session.ID, e.session.ID)
+	return e.run()
+}
+
+// Resume continues a stored session: tool calls that were in flight when it stopped are executed first,
+// then the conversation proceeds from the last message. followUp, if set, is sent as a new user message.
+func (e *Executor) Resume(sess *session.Session, followUp string) error {
+	finished := sess.Status == session.StatusCompleted || sess.Status == session.StatusPartial || sess.Status == session.StatusStopped
+	if finished && followUp == "" {
+		return fmt.Errorf("session %s has already finished, provide a follow-up message to continue it", sess.ID)
+	}
+
+	e.session = sess
+	e.checkpointCreated = sess.CheckpointID != ""
+	sess.Status = session.StatusRunning
+	sess.Error = ""
+	sess.Completion = nil
+	e.verifyFailures, e.completionErr = 0, nil
+	e.resetProgress()
+
+	if pending := sess.PendingToolCalls(); len(pending) > 0 {
+		e.infof("Running %d pending tool call(s) from the interrupted turn", len(pending))
+		completed, err := e.handleToolCalls(pending, &sess.Messages)
+		if err != nil {
+			e.finishSession(stoppedStatus(err), err)
+			return err
+		}
+		if completed && followUp == "" {
+			status, err := e.completionStatus()
+			e.finishSession(status, err)
+			return err
+		}
+	}
+
+	if followUp != "" {
+		sess.Messages = append(sess.Messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleUser,
+			Content: followUp,
+		})
+	}
+
+	e.saveSession()
+	return e.run()
+}
+
+// Completed reports whether the last executed or resumed task was marked complete by the model.
+func (e *Executor) Completed() bool {
+	return e.session != nil && e.session.Status == session.StatusCompleted
+}
+
+func (e *Executor) run() error {
+	e.warnUnpricedBudget()
+	status, err := e.loop(&e.session.Messages)
+	e.finishSession(status, err)
+	return err
+}
+
+func (e *Executor) loop(messages *[]openai.ChatCompletionMessage) (string, error) {
 	tools := e.getTools()
-	messages := e.createInitialMessages(structure, task)
 
 	for {
+		if err := e.checkBudget(); err != nil {
+			return session.StatusBudgetExceeded, err
+		}
+		if err := e.checkLimits(); err != nil {
+			e.warnf("Stopping: %v", err)
+			return session.StatusLimitReached, err
+		}
+
+		if err := e.manageContext(messages, tools); err != nil {
+			return session.StatusFailed, err
+		}
+
+		estimated := estimateTokens(*messages, tools)
 		startTime := time.Now()
-		fullResponse, err := e.createChatCompletion(messages, tools, startTime)
+		fullResponse, err := e.createChatCompletion(*messages, tools, startTime)
+		if errors.Is(err, ErrAborted) {
+			return session.StatusAborted, err
+		}
 		if err != nil {
-			return err
+			return session.StatusProviderError, err
+		}
+		e.progress.turns++
+		e.recordUsage(fullResponse.Usage)
+		if fullResponse.Usage.PromptTokens > 0 {
+			e.contextStats = contextStats{estimated: estimated, actual: fullResponse.Usage.PromptTokens}
 		}
 
 		if len(fullResponse.Choices) == 0 {
-			return fmt.Errorf("no choices returned by the model")
+			return session.StatusProviderError, fmt.Errorf("no choices returned by the model")
 		}
 
 		choice := fullResponse.Choices[0]
-		messages = append(messages, choice.Message)
+		*messages = append(*messages, choice.Message)
+		e.saveSession()
 
-		if err := e.logAIInteraction(messages, tools, fullResponse); err != nil {
-			fmt.Fprintf(os.Stderr, "Error logging AI interaction: %v\n", err)
+		if err := e.logAIInteraction(*messages, tools, fullResponse); err != nil {
+			e.errorf("Error logging AI interaction: %v", err)
 		}
 
 		if len(choice.Message.ToolCalls) == 0 {
-			if e.isTaskComplete(choice.Message.Content) {
-				fmt.Println("Task completed!")
-				break
-			}
-
-			fmt.Println("Empty response from AI, exiting...")
-			break
+			e.warnf("The model stopped without calling task_complete, exiting...")
+			return session.StatusStopped, nil
 		}
 
-		if e.handleToolCalls(choice.Message.ToolCalls, &messages) {
-			fmt.Println("Task completed!")
-			break
+		completed, err := e.handleToolCalls(choice.Message.ToolCalls, messages)
+		if err != nil {
+			return stoppedStatus(err), err
+		}
+		if completed {
+			return e.completionStatus()
 		}
 	}
+}
 
-	return nil
+func (e *Executor) saveSession() {
+	if err := e.session.Save(); err != nil {
+		e.errorf("Error saving session: %v", err)
+	}
+}
+
+func (e *Executor) finishSession(status string, err error) {
+	e.session.Status = status
+	if err != nil {
+		e.session.Error = err.Error()
+	}
+	e.saveSession()
+
+	report := e.Report()
+	e.emit(event.Event{Type: event.TaskFinished, Status: status, Error: e.session.Error, Usage: usageStats(e.session.Usage), Text: report.String(), Report: report})
+}
+
+// ensureCheckpoint snapshots the working tree once per task, right before the first file change.
+func (e *Executor) ensureCheckpoint() {
+	if e.checkpointCreated {
+		return
+	}
+	e.checkpointCreated = true
+
+	cp, err := checkpoint.Create(checkpointMessage(e.session.Task))
+	if err != nil {
+		e.warnf("Warning: could not create checkpoint, changes cannot be undone: %v", err)
+		return
+	}
+	e.session.CheckpointID = cp.ID
+	e.saveSession()
+	e.infof("Created checkpoint %s (restore with 'dwight undo %s')", cp.ID, cp.ID)
+}
+
+func checkpointMessage(task string) string {
+	return "Before task: " + taskSummary(task)
+}
+
+func taskSummary(task string) string {
+	firstLine := strings.TrimSpace(strings.SplitN(strings.TrimSpace(task), "\n", 2)[0])
+	if len(firstLine) > 72 {
+		firstLine = firstLine[:72] + "..."
+	}
+	return firstLine
 }
 
 func (e *Executor) logAIInteraction(messages []openai.ChatCompletionMessage, tools []openai.Tool, response openai.ChatCompletionResponse) error {
@@ -109,51 +271,34 @@ func (e *Executor) logAIInteraction(messages []openai.ChatCompletionMessage, too
 }
 
 func (e *Executor) createChatCompletion(messages []openai.ChatCompletionMessage, tools []openai.Tool, startTime time.Time) (openai.ChatCompletionResponse, error) {
-	done := make(chan bool)
-	var response openai.ChatCompletionResponse
-	var err error
-
-	go func() {
-		response, err = e.client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
-			Model:    e.cfg.Model,
-			Messages: messages,
-			Tools:    tools,
-		})
-		done <- true
-	}()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	e.showSpinnerUntilDone(done, startTime)
+	e.emit(event.Event{Type: event.RequestStarted})
+	onDelta := func(text string) {
+		e.emit(event.Event{Type: event.ModelMessage, Text: text})
+	}
 
+	response, err := e.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:    e.cfg.Model,
+		Messages: messages,
+		Tools:    tools,
+	}, onDelta)
 	if err != nil {
+		err = e.wrapStreamError(ctx, err)
+		e.emit(event.Event{Type: event.RequestFinished, Duration: time.Since(startTime).Seconds(), Error: err.Error()})
 		return openai.ChatCompletionResponse{}, err
 	}
 
-	fmt.Printf("\r\x1b[32mExecuting AI request... ✓ (%.1f s)\x1b[0m\n", time.Since(startTime).Seconds())
-
-	if len(response.Choices) > 0 {
-		message := response.Choices[0].Message
-		if message.Content != "" {
-			fmt.Printf("\x1b[34m\nModel message:\n\x1b[0m%s\n\n", message.Content)
-		}
-	}
-
+	e.emit(event.Event{Type: event.RequestFinished, Duration: time.Since(startTime).Seconds()})
 	return response, nil
 }
 
-func (e *Executor) showSpinnerUntilDone(done chan bool, startTime time.Time) {
-	spinnerChars := []string{"|", "/", "-", "\\"}
-	i := 0
-	for {
-		select {
-		case <-done:
-			return
-		default:
-			elapsed := time.Since(startTime).Seconds()
-			fmt.Printf("\r\x1b[36mExecuting AI request... %s (%.1f s)\x1b[0m", spinnerChars[i%len(spinnerChars)], elapsed)
-			time.Sleep(100 * time.Millisecond)
-			i++
-		}
+func (e *Executor) wrapStreamError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ErrAborted
 	}
+	return err
 }
 
 func (e *Executor) getTools() []openai.Tool {
@@ -162,19 +307,98 @@ func (e *Executor) getTools() []openai.Tool {
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
 				Name:        "get_file_contents",
-				Description: "Get contents of multiple files",
+				Description: "Get contents of multiple files with line numbers. Read only the lines you need from large files using start_line/end_line or around",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
 						"files": map[string]interface{}{
-							"type":  "array",
-							"items": map[string]interface{}{"type": "string"},
+							"type": "array",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"path": map[string]interface{}{
+										"type":        "string",
+										"description": "Path to the file",
+									},
+									"start_line": map[string]interface{}{
+										"type":        "integer",
+										"description": "First line to return, 1-based (defaults to the start of the file)",
+									},
+									"end_line": map[string]interface{}{
+										"type":        "integer",
+										"description": "Last line to return, inclusive (defaults to the end of the file)",
+									},
+									"around": map[string]interface{}{
+										"type":        "string",
+										"description": "Symbol name; returns the lines around its definition instead of a line range",
+									},
+								},
+								"required": []string{"path"},
+							},
 						},
 					},
 					"required": []string{"files"},
 				},
 			},
 		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "search_code",
+				Description: "Search project files for a regular expression or literal string and return file:line matches with surrounding context. Ignored files are skipped",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"pattern": map[string]interface{}{
+							"type":        "string",
+							"description": "Regular expression (RE2 syntax) matched against each line, or a literal string if literal is true",
+						},
+						"literal": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Treat pattern as a literal string",
+						},
+						"case_insensitive": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Match case-insensitively",
+						},
+						"path": map[string]interface{}{
+							"type":        "string",
+							"description": "File or directory to search in, relative to the project root (defaults to the project root)",
+						},
+						"include": map[string]interface{}{
+							"type":        "string",
+							"description": "Glob limiting which files are searched, e.g. **/*.go",
+						},
+						"context_lines": map[string]interface{}{
+							"type":        "integer",
+							"description": fmt.Sprintf("Lines of context around each match (default %d, max %d)", searchDefaultContext, searchMaxContext),
+						},
+						"max_results": map[string]interface{}{
+							"type":        "integer",
+							"description": fmt.Sprintf("Maximum number of matches to return (default %d, max %d)", searchDefaultResults, searchMaxResults),
+						},
+					},
+					"required": []string{"pattern"},
+				},
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "list_directory",
+				Description: "List a directory of the project with file sizes and outlines, e.g. to expand a directory collapsed in the project structure. Large subdirectories may again be collapsed",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"path": map[string]interface{}{
+							"type":        "string",
+							"description": "Directory to list, relative to the project root",
+						},
+					},
+					"required": []string{"path"},
+				},
+			},
+		},
 		{
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
@@ -205,11 +429,93 @@ func (e *Executor) getTools() []openai.Tool {
 				},
 			},
 		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "edit_file",
+				Description: "Edit an existing file by replacing exact strings. Each old_string must match exactly once in the file",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"file_path": map[string]interface{}{
+							"type":        "string",
+							"description": "Path to the file to edit",
+						},
+						"edits": map[string]interface{}{
+							"type": "array",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"old_string": map[string]interface{}{
+										"type":        "string",
+										"description": "Exact text to replace, including whitespace and indentation",
+									},
+									"new_string": map[string]interface{}{
+										"type":        "string",
+										"description": "Replacement text",
+									},
+								},
+								"required": []string{"old_string", "new_string"},
+							},
+						},
+					},
+					"required": []string{"file_path", "edits"},
+				},
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "delete_files",
+				Description: "Delete files. Directories cannot be deleted directly, delete the files in them instead",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"paths": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]interface{}{"type": "string"},
+							"description": "Paths of the files to delete",
+						},
+					},
+					"required": []string{"paths"},
+				},
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "move_files",
+				Description: "Move or rename files, creating destination directories as needed. Existing destinations are never overwritten",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"moves": map[string]interface{}{
+							"type": "array",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"from": map[string]interface{}{
+										"type":        "string",
+										"description": "Current path of the file",
+									},
+									"to": map[string]interface{}{
+										"type":        "string",
+										"description": "New path of the file",
+									},
+								},
+								"required": []string{"from", "to"},
+							},
+						},
+					},
+					"required": []string{"moves"},
+				},
+			},
+		},
 		{
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
 				Name:        "run_command",
-				Description: "Execute a shell command (sh -c <your_command>)",
+				Description: "Execute a shell command (sh -c <your_command>). Long-running commands are killed after a timeout and large output is truncated",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
@@ -217,6 +523,10 @@ func (e *Executor) getTools() []openai.Tool {
 							"type":        "string",
 							"description": "Shell command to execute",
 						},
+						"working_dir": map[string]interface{}{
+							"type":        "string",
+							"description": "Directory to run the command in, relative to the project root (defaults to the project root)",
+						},
 					},
 					"required": []string{"command"},
 				},
@@ -243,10 +553,35 @@ func (e *Executor) getTools() []openai.Tool {
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
 				Name:        "task_complete",
-				Description: "Mark the task as completed",
+				Description: "Finish the task and report its outcome. Call it also when the task cannot be completed, with status failed",
 				Parameters: map[string]interface{}{
-					"type":       "object",
-					"properties": map[string]interface{}{},
+					"type": "object",
+					"properties": map[string]interface{}{
+						"status": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{session.CompletionSuccess, session.CompletionPartial, session.CompletionFailed},
+							"description": "success if the task is fully done, partial if only some of it is, failed if it could not be done",
+						},
+						"summary": map[string]interface{}{
+							"type":        "string",
+							"description": "What was done, and why parts were not done if the status is not success",
+						},
+						"files_changed": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]interface{}{"type": "string"},
+							"description": "Paths of the files that were created, modified, deleted or moved",
+						},
+						"verification": map[string]interface{}{
+							"type":        "string",
+							"description": "How the changes were verified, e.g. the build and test commands that were run and their results, or that they were not verified",
+						},
+						"open_issues": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]interface{}{"type": "string"},
+							"description": "Known problems, remaining work and assumptions the user should review",
+						},
+					},
+					"required": []string{"status", "summary", "files_changed", "verification", "open_issues"},
 				},
 			},
 		},
@@ -254,6 +589,9 @@ func (e *Executor) getTools() []openai.Tool {
 }
 
 func (e *Executor) createInitialMessages(structure, task string) []openai.ChatCompletionMessage {
+	if e.cfg.VerifyCommand != "" {
+		task += fmt.Sprintf("\n\nBefore completion is accepted, '%s' is run to verify the changes; it must succeed.", e.cfg.VerifyCommand)
+	}
 	return []openai.ChatCompletionMessage{
 		{
 			Role:    openai.ChatMessageRoleSystem,
@@ -266,24 +604,43 @@ func (e *Executor) createInitialMessages(structure, task string) []openai.ChatCo
 	}
 }
 
-func (e *Executor) isTaskComplete(content string) bool {
-	lowerContent := strings.ToLower(content)
-	return strings.Contains(lowerContent, "done") ||
-		strings.Contains(lowerContent, "complete") ||
-		strings.Contains(lowerContent, "finished")
-}
-
-func (e *Executor) handleToolCalls(toolCalls []openai.ToolCall, messages *[]openai.ChatCompletionMessage) bool {
-	taskComplete := false
+// handleToolCalls runs tool calls in order and reports whether the model completed the task. It stops with
+// ErrInputRequired when a non-interactive run needs the user, and with ErrLimitReached when the tool call
+// or duration limit is hit; the remaining calls stay pending.
+func (e *Executor) handleToolCalls(toolCalls []openai.ToolCall, messages *[]openai.ChatCompletionMessage) (bool, error) {
+	var nudge []openai.ChatCompletionMessage
 	for _, toolCall := range toolCalls {
-		if toolCall.Function.Name == "task_complete" {
-			taskComplete = true
-			continue
+		if err := e.checkToolCallLimits(); err != nil {
+			e.warnf("Stopping: %v", err)
+			e.saveSession()
+			return false, err
 		}
-
+		e.emit(event.Event{Type: event.ToolCall, Tool: toolCall.Function.Name, ToolCallID: toolCall.ID, Arguments: toolCall.Function.Arguments})
+		if e.trackToolCall(toolCall) {
+			nudge = append(nudge, repeatNudge(toolCall, e.progress.repeats))
+		}
+		before := len(*messages)
 		if err := e.handleToolCall(toolCall, messages); err != nil {
-			fmt.Fprintf(os.Stderr, "Error handling tool call: %v\n", err)
+			e.errorf("Error handling tool call: %v", err)
+		}
+		if err := e.inputRequired; err != nil {
+			e.inputRequired = nil
+			*messages = (*messages)[:before]
+			e.saveSession()
+			return false, err
 		}
+		e.redactToolResults((*messages)[before:], toolCall.Function.Name+" result")
+		for _, msg := range (*messages)[before:] {
+			if msg.Role == openai.ChatMessageRoleTool {
+				e.emit(event.Event{Type: event.ToolResult, Tool: toolCall.Function.Name, ToolCallID: msg.ToolCallID, Text: msg.Content})
+			}
+		}
+		e.saveSession()
+	}
+	if len(nudge) > 0 {
+		e.warnf("The model keeps repeating the same tool call, asking it to change its approach")
+		*messages = append(*messages, nudge...)
+		e.saveSession()
 	}
-	return taskComplete
+	return e.session.Completion != nil, nil
 }
@@ -0,0 +1,447 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rofleksey/dwight/api"
+	"github.com/rofleksey/dwight/config"
+	"github.com/rofleksey/dwight/event"
+	"github.com/rofleksey/dwight/policy"
+	"github.com/rofleksey/dwight/session"
+	"github.com/rofleksey/dwight/util"
+	"github.com/sashabaranov/go-openai"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []event.Event
+}
+
+func (r *recorder) Emit(e event.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) last(typ event.Type) (event.Event, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.events) - 1; i >= 0; i-- {
+		if r.events[i].Type == typ {
+			return r.events[i], true
+		}
+	}
+	return event.Event{}, false
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Model:             "test-model",
+		MaxOutputTokens:   1024,
+		ContextWindow:     128000,
+		CompactAt:         0.8,
+		SnippetMaxLines:   50,
+		ReadMaxBytes:      100 * 1024,
+		OutlineDepth:      2,
+		CommandTimeout:    10 * time.Second,
+		CommandMaxOutput:  64 * 1024,
+		CommandSandbox:    "none",
+		RedactSecrets:     true,
+		ReviewMode:        "file",
+		VerifyMaxAttempts: 3,
+		MaxTurns:          100,
+		MaxToolCalls:      300,
+		RepeatLimit:       3,
+	}
+}
+
+// setupProject changes into an empty project directory with an isolated home and approves confirmations.
+func setupProject(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("HOME", t.TempDir())
+	util.SetAutoConfirm(true)
+	t.Cleanup(func() { util.SetAutoConfirm(false) })
+}
+
+func newTestExecutor(t *testing.T, cfg *config.Config, responses ...api.ScriptedResponse) (*Executor, *api.ScriptedProvider, *recorder) {
+	t.Helper()
+	pol, err := policy.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := api.NewScriptedProviderWithResponses(responses)
+	events := &recorder{}
+	return NewExecutor(provider, cfg, pol, events), provider, events
+}
+
+func call(name string, args any) api.ScriptedToolCall {
+	data, err := json.Marshal(args)
+	if err != nil {
+		panic(err)
+	}
+	return api.ScriptedToolCall{Name: name, Arguments: data}
+}
+
+func complete(status string) api.ScriptedResponse {
+	return api.ScriptedResponse{ToolCalls: []api.ScriptedToolCall{call("task_complete", map[string]any{
+		"status":        status,
+		"summary":       "Did the " + status + " thing",
+		"files_changed": []string{},
+		"verification":  "ran nothing",
+		"open_issues":   []string{},
+	})}}
+}
+
+func writeFile(path string) api.ScriptedResponse {
+	return api.ScriptedResponse{ToolCalls: []api.ScriptedToolCall{call("modify_files", map[string]any{
+		"files": []map[string]string{{"file_path": path, "content": "hello\n"}},
+	})}}
+}
+
+func readFile(path string) api.ScriptedResponse {
+	return api.ScriptedResponse{ToolCalls: []api.ScriptedToolCall{call("get_file_contents", map[string]any{
+		"files": []map[string]string{{"path": path}},
+	})}}
+}
+
+func TestExecuteCompletesTask(t *testing.T) {
+	setupProject(t)
+	executor, _, events := newTestExecutor(t, testConfig(), writeFile("hello.txt"), complete(session.CompletionSuccess))
+
+	if err := executor.Execute("create hello.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := os.ReadFile("hello.txt"); err != nil || string(data) != "hello\n" {
+		t.Errorf("hello.txt = %q, %v", data, err)
+	}
+	report := executor.Report()
+	if report.Status != session.StatusCompleted || executor.ExitCode() != ExitCompleted {
+		t.Errorf("status = %s (exit %d), want completed", report.Status, executor.ExitCode())
+	}
+	if len(report.Changes) != 1 || report.Changes[0] != (session.FileChange{Path: "hello.txt", Kind: session.ChangeCreated}) {
+		t.Errorf("changes = %+v, want hello.txt created", report.Changes)
+	}
+	if report.Summary != "Did the success thing" {
+		t.Errorf("summary = %q", report.Summary)
+	}
+	if finished, ok := events.last(event.TaskFinished); !ok || finished.Status != session.StatusCompleted {
+		t.Errorf("task_finished event = %+v, %v", finished, ok)
+	}
+
+	stored, err := session.Load(report.Session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != session.StatusCompleted || stored.Completion == nil {
+		t.Errorf("stored session status = %s, completion = %v", stored.Status, stored.Completion)
+	}
+}
+
+func TestExecuteExitStatuses(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		responses []api.ScriptedResponse
+		status    string
+		exitCode  int
+		wantErr   bool
+	}{
+		{
+			name:      "text without task_complete",
+			responses: []api.ScriptedResponse{{Content: "I could not complete this"}},
+			status:    session.StatusStopped,
+			exitCode:  ExitGaveUp,
+		},
+		{
+			name:      "partial",
+			responses: []api.ScriptedResponse{complete(session.CompletionPartial)},
+			status:    session.StatusPartial,
+			exitCode:  ExitPartial,
+		},
+		{
+			name:      "failed",
+			responses: []api.ScriptedResponse{complete(session.CompletionFailed)},
+			status:    session.StatusGaveUp,
+			exitCode:  ExitGaveUp,
+			wantErr:   true,
+		},
+		{
+			name:      "invalid completion is sent back",
+			responses: []api.ScriptedResponse{complete("done"), complete(session.CompletionSuccess)},
+			status:    session.StatusCompleted,
+			exitCode:  ExitCompleted,
+		},
+		{
+			name:      "provider error",
+			responses: []api.ScriptedResponse{{Error: "503 service unavailable"}},
+			status:    session.StatusProviderError,
+			exitCode:  ExitProviderError,
+			wantErr:   true,
+		},
+		{
+			name:      "script exhausted",
+			responses: []api.ScriptedResponse{writeFile("a.txt")},
+			status:    session.StatusProviderError,
+			exitCode:  ExitProviderError,
+			wantErr:   true,
+		},
+		{
+			name:      "budget exceeded",
+			configure: func(cfg *config.Config) { cfg.MaxTokens = 100 },
+			responses: []api.ScriptedResponse{
+				{ToolCalls: readFile("a.txt").ToolCalls, Usage: &openai.Usage{PromptTokens: 90, CompletionTokens: 20}},
+				complete(session.CompletionSuccess),
+			},
+			status:   session.StatusBudgetExceeded,
+			exitCode: ExitBudgetExceeded,
+			wantErr:  true,
+		},
+		{
+			name:      "max turns",
+			configure: func(cfg *config.Config) { cfg.MaxTurns = 2 },
+			responses: []api.ScriptedResponse{readFile("a.txt"), readFile("b.txt"), complete(session.CompletionSuccess)},
+			status:    session.StatusLimitReached,
+			exitCode:  ExitLimitReached,
+			wantErr:   true,
+		},
+		{
+			name:      "verification passes after a fix",
+			configure: func(cfg *config.Config) { cfg.VerifyCommand = "test -f fixed.txt" },
+			responses: []api.ScriptedResponse{complete(session.CompletionSuccess), writeFile("fixed.txt"), complete(session.CompletionSuccess)},
+			status:    session.StatusCompleted,
+			exitCode:  ExitCompleted,
+		},
+		{
+			name: "verification keeps failing",
+			configure: func(cfg *config.Config) {
+				cfg.VerifyCommand = "false"
+				cfg.VerifyMaxAttempts = 2
+			},
+			responses: []api.ScriptedResponse{complete(session.CompletionSuccess), complete(session.CompletionSuccess)},
+			status:    session.StatusFailed,
+			exitCode:  ExitFailed,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupProject(t)
+			cfg := testConfig()
+			if tt.configure != nil {
+				tt.configure(cfg)
+			}
+			executor, _, _ := newTestExecutor(t, cfg, tt.responses...)
+
+			err := executor.Execute("do it")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, want error %v", err, tt.wantErr)
+			}
+			if report := executor.Report(); report.Status != tt.status || report.ExitCode != tt.exitCode {
+				t.Errorf("status = %s (exit %d), want %s (exit %d)", report.Status, report.ExitCode, tt.status, tt.exitCode)
+			}
+		})
+	}
+}
+
+func TestExecuteVerificationFailureIsSentBack(t *testing.T) {
+	setupProject(t)
+	cfg := testConfig()
+	cfg.VerifyCommand = "echo broken build; exit 1"
+	executor, provider, _ := newTestExecutor(t, cfg, complete(session.CompletionSuccess), complete(session.CompletionFailed))
+
+	if err := executor.Execute("do it"); err == nil {
+		t.Fatal("Execute() succeeded, want the failed completion reported")
+	}
+
+	requests := provider.Requests()
+	last := requests[len(requests)-1].Messages
+	result := last[len(last)-1]
+	if result.Role != openai.ChatMessageRoleTool || !strings.Contains(result.Content, "broken build") || !strings.Contains(result.Content, "not complete") {
+		t.Errorf("verification result sent to the model = %+v", result)
+	}
+	if commands := executor.Report().Commands; len(commands) != 1 || commands[0].ExitCode != 1 {
+		t.Errorf("commands = %+v, want the failed verification", commands)
+	}
+}
+
+func TestExecuteNudgesRepeatedToolCalls(t *testing.T) {
+	setupProject(t)
+	if err := os.WriteFile("a.txt", []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	responses := make([]api.ScriptedResponse, 0, 8)
+	for i := 0; i < 8; i++ {
+		responses = append(responses, readFile("a.txt"))
+	}
+	executor, provider, _ := newTestExecutor(t, testConfig(), responses...)
+
+	err := executor.Execute("read a.txt")
+	if !errors.Is(err, ErrLimitReached) {
+		t.Fatalf("Execute() error = %v, want ErrLimitReached", err)
+	}
+
+	nudged := false
+	for _, msg := range provider.Requests()[3].Messages {
+		if msg.Role == openai.ChatMessageRoleUser && strings.Contains(msg.Content, "same arguments 3 times") {
+			nudged = true
+		}
+	}
+	if !nudged {
+		t.Error("the model was not nudged after the third identical call")
+	}
+	if got := len(provider.Requests()); got != 6 {
+		t.Errorf("made %d requests, want to stop after 6 identical calls", got)
+	}
+}
+
+func TestExecuteNonInteractiveRequiresInput(t *testing.T) {
+	setupProject(t)
+	util.SetAutoConfirm(false)
+	executor, _, _ := newTestExecutor(t, testConfig(), writeFile("a.txt"), complete(session.CompletionSuccess))
+	answers, err := LoadAnswers("", UnansweredAssume)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor.SetNonInteractive(answers)
+
+	err = executor.Execute("create a.txt")
+	if !errors.Is(err, ErrInputRequired) || executor.ExitCode() != ExitInputRequired {
+		t.Fatalf("Execute() error = %v (exit %d), want ErrInputRequired", err, executor.ExitCode())
+	}
+	if _, err := os.Stat("a.txt"); !os.IsNotExist(err) {
+		t.Error("a.txt was written without confirmation")
+	}
+
+	sess, err := session.Load(executor.Report().Session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending := sess.PendingToolCalls(); len(pending) != 1 || pending[0].Function.Name != "modify_files" {
+		t.Errorf("pending tool calls = %+v, want the unconfirmed modify_files", pending)
+	}
+}
+
+func TestExecuteStopsAtToolCallLimitWithinResponse(t *testing.T) {
+	setupProject(t)
+	cfg := testConfig()
+	cfg.MaxToolCalls = 2
+	writes := api.ScriptedResponse{ToolCalls: append(append(writeFile("a.txt").ToolCalls, writeFile("b.txt").ToolCalls...), writeFile("c.txt").ToolCalls...)}
+	executor, _, _ := newTestExecutor(t, cfg, writes)
+
+	err := executor.Execute("create three files")
+	if !errors.Is(err, ErrLimitReached) || executor.ExitCode() != ExitLimitReached {
+		t.Fatalf("Execute() error = %v (exit %d), want ErrLimitReached", err, executor.ExitCode())
+	}
+	if _, err := os.Stat("c.txt"); !os.IsNotExist(err) {
+		t.Error("c.txt was written after the tool call limit was reached")
+	}
+
+	sess, err := session.Load(executor.Report().Session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := sess.PendingToolCalls()
+	if len(pending) != 1 || !strings.Contains(pending[0].Function.Arguments, "c.txt") {
+		t.Fatalf("pending tool calls = %+v, want the write of c.txt", pending)
+	}
+
+	resumed, _, _ := newTestExecutor(t, testConfig(), complete(session.CompletionSuccess))
+	if err := resumed.Resume(sess, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("c.txt"); err != nil {
+		t.Errorf("c.txt was not written on resume: %v", err)
+	}
+}
+
+func interruptedSession(t *testing.T, calls []openai.ToolCall, answered int) *session.Session {
+	t.Helper()
+	sess := session.New("create files")
+	sess.Messages = []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "system"},
+		{Role: openai.ChatMessageRoleUser, Content: "create files"},
+		{Role: openai.ChatMessageRoleAssistant, ToolCalls: calls},
+	}
+	for _, c := range calls[:answered] {
+		sess.Messages = append(sess.Messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleTool, ToolCallID: c.ID, Content: "done before the interruption"})
+	}
+	if err := sess.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := session.Load(sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loaded
+}
+
+func toolCalls(response api.ScriptedResponse, id string) []openai.ToolCall {
+	var calls []openai.ToolCall
+	for _, c := range response.ToolCalls {
+		calls = append(calls, openai.ToolCall{ID: id, Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: c.Name, Arguments: string(c.Arguments)}})
+	}
+	return calls
+}
+
+func TestResumeRunsPendingToolCalls(t *testing.T) {
+	setupProject(t)
+	if err := os.WriteFile("a.txt", []byte("kept\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	calls := append(toolCalls(writeFile("a.txt"), "call_0"), toolCalls(writeFile("b.txt"), "call_1")...)
+	sess := interruptedSession(t, calls, 1)
+
+	executor, provider, _ := newTestExecutor(t, testConfig(), complete(session.CompletionSuccess))
+	if err := executor.Resume(sess, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := os.ReadFile("a.txt"); err != nil || string(data) != "kept\n" {
+		t.Errorf("a.txt = %q, %v, want the answered call not to run again", data, err)
+	}
+	if _, err := os.Stat("b.txt"); err != nil {
+		t.Errorf("pending write of b.txt was not run: %v", err)
+	}
+	messages := provider.Requests()[0].Messages
+	if len(messages) != 5 || messages[3].ToolCallID != "call_0" || messages[4].ToolCallID != "call_1" || !strings.Contains(messages[4].Content, "b.txt: Updated") {
+		t.Errorf("resumed conversation = %+v, want the results of both calls after the interrupted turn", messages)
+	}
+	if executor.ExitCode() != ExitCompleted {
+		t.Errorf("exit code = %d, want %d", executor.ExitCode(), ExitCompleted)
+	}
+}
+
+func TestResumePendingCompletion(t *testing.T) {
+	setupProject(t)
+	sess := interruptedSession(t, toolCalls(complete(session.CompletionSuccess), "call_0"), 0)
+
+	executor, provider, _ := newTestExecutor(t, testConfig())
+	if err := executor.Resume(sess, ""); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(provider.Requests()); n != 0 {
+		t.Errorf("model was asked %d more time(s) after the pending task_complete", n)
+	}
+
+	stored, err := session.Load(sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != session.StatusCompleted || stored.Completion == nil || len(stored.PendingToolCalls()) != 0 {
+		t.Errorf("stored session = %s, completion %v, pending %v", stored.Status, stored.Completion, stored.PendingToolCalls())
+	}
+
+	finished, _, _ := newTestExecutor(t, testConfig())
+	if err := finished.Resume(stored, ""); err == nil || !strings.Contains(err.Error(), "already finished") {
+		t.Errorf("Resume() of a finished session without follow-up = %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rofleksey/dwight/session"
+	"github.com/sashabaranov/go-openai"
+)
+
+var ErrLimitReached = errors.New("limit reached")
+
+// progress counts the work done since the task was started or resumed, for the loop limits.
+type progress struct {
+	started   time.Time
+	turns     int
+	toolCalls int
+
+	lastCall string
+	repeats  int
+}
+
+func (e *Executor) resetProgress() {
+	e.progress = progress{started: time.Now()}
+}
+
+func (e *Executor) checkLimits() error {
+	p := e.progress
+	if e.cfg.MaxTurns > 0 && p.turns >= e.cfg.MaxTurns {
+		return fmt.Errorf("%w: made %d of %d model requests", ErrLimitReached, p.turns, e.cfg.MaxTurns)
+	}
+	if err := e.checkToolCallLimits(); err != nil {
+		return err
+	}
+	// The model was nudged once at RepeatLimit repetitions; it is stuck if it keeps going.
+	if e.cfg.RepeatLimit > 0 && p.repeats >= 2*e.cfg.RepeatLimit {
+		return fmt.Errorf("%w: the same tool call was repeated %d times in a row", ErrLimitReached, p.repeats)
+	}
+	return nil
+}
+
+// checkToolCallLimits checks the limits a single response with many tool calls can run into, before
+// each of its calls.
+func (e *Executor) checkToolCallLimits() error {
+	p := e.progress
+	if e.cfg.MaxToolCalls > 0 && p.toolCalls >= e.cfg.MaxToolCalls {
+		return fmt.Errorf("%w: made %d of %d tool calls", ErrLimitReached, p.toolCalls, e.cfg.MaxToolCalls)
+	}
+	if elapsed := time.Since(p.started); e.cfg.MaxDuration > 0 && elapsed >= e.cfg.MaxDuration {
+		return fmt.Errorf("%w: ran for %s of %s", ErrLimitReached, elapsed.Round(time.Second), e.cfg.MaxDuration)
+	}
+	return nil
+}
+
+// stoppedStatus is the session status for an error that stopped handleToolCalls.
+func stoppedStatus(err error) string {
+	if errors.Is(err, ErrLimitReached) {
+		return session.StatusLimitReached
+	}
+	return session.StatusInputRequired
+}
+
+// trackToolCall counts a tool call and reports whether it repeated the previous calls often enough
+// to nudge the model.
+func (e *Executor) trackToolCall(toolCall openai.ToolCall) bool {
+	p := &e.progress
+	p.toolCalls++
+
+	call := toolCall.Function.Name + " " + canonicalArguments(toolCall.Function.Arguments)
+	if call == p.lastCall {
+		p.repeats++
+	} else {
+		p.lastCall, p.repeats = call, 1
+	}
+	return e.cfg.RepeatLimit > 0 && p.repeats == e.cfg.RepeatLimit
+}
+
+// canonicalArguments normalizes JSON arguments, so calls differing only in formatting or key order are equal.
+func canonicalArguments(arguments string) string {
+	var v any
+	if err := json.Unmarshal([]byte(arguments), &v); err != nil {
+		return arguments
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return arguments
+	}
+	return string(data)
+}
+
+func repeatNudge(toolCall openai.ToolCall, repeats int) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleUser,
+		Content: fmt.Sprintf("You have called %s with the same arguments %d times in a row. Repeating it will not change the result. "+
+			"Use the results you already have, try a different approach, or call task_complete with status partial or failed if you are stuck.",
+			toolCall.Function.Name, repeats),
+	}
+}
@@ -0,0 +1,82 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestCanonicalArguments(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{a: `{"path": "a.go", "line": 1}`, b: `{"line":1,"path":"a.go"}`, equal: true},
+		{a: "{\n  \"files\": [{\"path\": \"a.go\"}]\n}", b: `{"files":[{"path":"a.go"}]}`, equal: true},
+		{a: `{"path": "a.go"}`, b: `{"path": "b.go"}`, equal: false},
+		{a: `{"files": ["a", "b"]}`, b: `{"files": ["b", "a"]}`, equal: false},
+		{a: `not json`, b: `not json`, equal: true},
+		{a: `not json`, b: `not  json`, equal: false},
+	}
+	for _, tt := range tests {
+		if got := canonicalArguments(tt.a) == canonicalArguments(tt.b); got != tt.equal {
+			t.Errorf("canonicalArguments(%q) == canonicalArguments(%q) is %v, want %v", tt.a, tt.b, got, tt.equal)
+		}
+	}
+}
+
+func TestTrackToolCall(t *testing.T) {
+	cfg := testConfig()
+	cfg.RepeatLimit = 3
+	e := &Executor{cfg: cfg}
+	e.resetProgress()
+
+	toolCall := func(name, arguments string) openai.ToolCall {
+		return openai.ToolCall{Function: openai.FunctionCall{Name: name, Arguments: arguments}}
+	}
+	calls := []struct {
+		call    openai.ToolCall
+		repeats int
+		nudge   bool
+	}{
+		{call: toolCall("get_file_contents", `{"path": "a.go"}`), repeats: 1},
+		{call: toolCall("get_file_contents", `{"path":"a.go"}`), repeats: 2},
+		{call: toolCall("get_file_contents", `{ "path" : "a.go" }`), repeats: 3, nudge: true},
+		{call: toolCall("get_file_contents", `{"path": "a.go"}`), repeats: 4},
+		{call: toolCall("search_code", `{"path": "a.go"}`), repeats: 1},
+		{call: toolCall("get_file_contents", `{"path": "a.go"}`), repeats: 1},
+	}
+	for i, c := range calls {
+		if nudge := e.trackToolCall(c.call); nudge != c.nudge || e.progress.repeats != c.repeats {
+			t.Errorf("call %d: nudge = %v, repeats = %d, want %v, %d", i, nudge, e.progress.repeats, c.nudge, c.repeats)
+		}
+	}
+	if e.progress.toolCalls != len(calls) {
+		t.Errorf("counted %d tool calls, want %d", e.progress.toolCalls, len(calls))
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxTurns, cfg.MaxToolCalls, cfg.RepeatLimit = 5, 10, 3
+	tests := []struct {
+		name     string
+		progress progress
+		wantErr  bool
+	}{
+		{name: "within limits", progress: progress{turns: 4, toolCalls: 9, repeats: 5}},
+		{name: "turns", progress: progress{turns: 5}, wantErr: true},
+		{name: "tool calls", progress: progress{toolCalls: 10}, wantErr: true},
+		{name: "repeats after the nudge", progress: progress{repeats: 6}, wantErr: true},
+	}
+	for _, tt := range tests {
+		e := &Executor{cfg: cfg}
+		e.resetProgress()
+		started := e.progress.started
+		e.progress = tt.progress
+		e.progress.started = started
+		if err := e.checkLimits(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkLimits() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/rofleksey/dwight/util/ignore"
+)
+
+// alwaysProtected can never be written by the model: git internals, dwight's own state including the
+// project policy, which would otherwise let the model grant itself permissions, and the ignore files,
+// which would let it re-include files the user excluded.
+var alwaysProtected = []string{"**/.git/**", ".dwight/**", "**/.gitignore", "**/.dwightignore"}
+
+// resolveWritePath validates a path the model wants to write and returns it relative to the project root.
+// Symlinks are resolved, so a link inside the project cannot redirect the write elsewhere, and the
+// target must be neither protected nor ignored by the user's own ignore files. The built-in ignore defaults
+// only keep files such as go.sum and LICENSE out of reads, the model may still write them.
+func (e *Executor) resolveWritePath(path string) (string, error) {
+	return e.resolvePath(path, true)
+}
+
+// resolveEntryPath is resolveWritePath for operations on the directory entry itself, such as deleting or
+// moving: a symlink in the last path component is not followed, so the link is changed and not its target.
+func (e *Executor) resolveEntryPath(path string) (string, error) {
+	return e.resolvePath(path, false)
+}
+
+func (e *Executor) resolvePath(path string, followLast bool) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		return "", fmt.Errorf("file path is empty")
+	}
+
+	root, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(root, abs)
+	}
+	rel, inside := relativeTo(root, abs)
+	if !inside {
+		return "", fmt.Errorf("%s is outside of the project root", path)
+	}
+
+	var resolved string
+	if followLast {
+		resolved, err = resolveExisting(abs)
+	} else {
+		resolved, err = resolveExisting(filepath.Dir(abs))
+		resolved = filepath.Join(resolved, filepath.Base(abs))
+	}
+	if err != nil {
+		return "", err
+	}
+	realRel, inside := relativeTo(realRoot, resolved)
+	if !inside {
+		return "", fmt.Errorf("%s resolves to %s, which is outside of the project root", path, resolved)
+	}
+	if realRel == "." {
+		return "", fmt.Errorf("%s is the project root", path)
+	}
+
+	ignored, err := e.writeIgnoreMatcher()
+	if err != nil {
+		return "", err
+	}
+	patterns := append(append([]string(nil), alwaysProtected...), e.cfg.ProtectedPaths...)
+	for _, candidate := range []string{rel, realRel} {
+		for _, pattern := range patterns {
+			if matched, _ := doublestar.Match(pattern, filepath.ToSlash(candidate)); matched {
+				return "", fmt.Errorf("%s is protected (%s)", path, pattern)
+			}
+		}
+		if ignored.Ignored(candidate, false) {
+			return "", fmt.Errorf("%s is excluded by ignore patterns", path)
+		}
+	}
+
+	return realRel, nil
+}
+
+// resolveExisting resolves symlinks in the longest existing prefix of path, so files that are about
+// to be created are resolved through their existing parent directories.
+func resolveExisting(path string) (string, error) {
+	var missing []string
+	current := filepath.Clean(path)
+	for {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		// A dangling symlink would be followed by the write, wherever it points.
+		if _, lerr := os.Lstat(current); lerr == nil {
+			return "", fmt.Errorf("%s is a broken symlink", current)
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", err
+		}
+		missing = append(missing, filepath.Base(current))
+		current = parent
+	}
+}
+
+func relativeTo(root, path string) (string, bool) {
+	rel, err := filepath.Rel(root, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// removeEmptyParents removes directories left empty after a file was deleted or moved away from dir,
+// stopping at the project root.
+func removeEmptyParents(dir string) {
+	for dir != "." && dir != "" && !filepath.IsAbs(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// ignoreMatcher returns the ignore matcher of the project, loading it on first use. The model cannot
+// write ignore files, so it stays valid for the whole task.
+func (e *Executor) ignoreMatcher() (*ignore.Matcher, error) {
+	if e.ignored == nil {
+		ignored, err := ignore.Load(e.cfg.UseGitignore)
+		if err != nil {
+			return nil, err
+		}
+		e.ignored = ignored
+	}
+	return e.ignored, nil
+}
+
+// writeIgnoreMatcher is ignoreMatcher without the built-in defaults, for checking writes.
+func (e *Executor) writeIgnoreMatcher() (*ignore.Matcher, error) {
+	if e.writeIgnored == nil {
+		ignored, err := ignore.LoadWithoutDefaults(e.cfg.UseGitignore)
+		if err != nil {
+			return nil, err
+		}
+		e.writeIgnored = ignored
+	}
+	return e.writeIgnored, nil
+}
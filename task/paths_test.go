@@ -0,0 +1,97 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveWritePath(t *testing.T) {
+	setupProject(t)
+	outside := t.TempDir()
+	for path, content := range map[string]string{
+		".gitignore":      "*.log\nbuild/\n",
+		"go.sum":          "",
+		"src/main.go":     "",
+		"build/out.txt":   "",
+		"secrets/key.pem": "",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"out":      outside,
+		"logs":     "build",
+		"main.lnk": "src/main.go",
+	} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := testConfig()
+	cfg.UseGitignore = true
+	cfg.ProtectedPaths = []string{"secrets/**"}
+	executor, _, _ := newTestExecutor(t, cfg)
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr string
+	}{
+		{path: "src/main.go", want: "src/main.go"},
+		{path: "src/new/file.go", want: "src/new/file.go"},
+		{path: "main.lnk", want: "src/main.go"},
+		// Only the built-in defaults ignore these, so they can be written.
+		{path: "go.sum", want: "go.sum"},
+		{path: "LICENSE", want: "LICENSE"},
+		{path: "", wantErr: "empty"},
+		{path: ".", wantErr: "project root"},
+		{path: "../escape.txt", wantErr: "outside of the project root"},
+		{path: "out/file.txt", wantErr: "outside of the project root"},
+		{path: ".git/config", wantErr: "protected"},
+		{path: ".dwight/policy.yaml", wantErr: "protected"},
+		{path: "secrets/key.pem", wantErr: "protected"},
+		// Ignore files could re-include files the user excluded.
+		{path: ".gitignore", wantErr: "protected"},
+		{path: "src/.gitignore", wantErr: "protected"},
+		{path: ".dwightignore", wantErr: "protected"},
+		{path: "src/new/.dwightignore", wantErr: "protected"},
+		{path: ".git/info/exclude", wantErr: "protected"},
+		{path: "debug.log", wantErr: "excluded by ignore patterns"},
+		{path: "build/out.txt", wantErr: "excluded by ignore patterns"},
+		{path: "logs/out.txt", wantErr: "excluded by ignore patterns"},
+	}
+	for _, tt := range tests {
+		got, err := executor.resolveWritePath(tt.path)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("resolveWritePath(%q) = %q, %v, want error containing %q", tt.path, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolveWritePath(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestResolveEntryPathKeepsSymlink(t *testing.T) {
+	setupProject(t)
+	if err := os.Symlink(t.TempDir(), "out"); err != nil {
+		t.Fatal(err)
+	}
+	executor, _, _ := newTestExecutor(t, testConfig())
+
+	if got, err := executor.resolveEntryPath("out"); err != nil || got != "out" {
+		t.Errorf("resolveEntryPath(out) = %q, %v, want the link itself", got, err)
+	}
+	if _, err := executor.resolveWritePath("out"); err == nil {
+		t.Error("resolveWritePath(out) followed the link outside of the project")
+	}
+}
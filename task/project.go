@@ -1,62 +1,405 @@
 package task
 
 import (
+	"bytes"
+	"container/heap"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
-	"github.com/rofleksey/dwight/util"
+	"github.com/rofleksey/dwight/outline"
+	"github.com/rofleksey/dwight/policy"
 	"github.com/rofleksey/dwight/util/ignore"
+	"github.com/rofleksey/dwight/vcs"
 )
 
-func (e *Executor) getProjectStructure() (string, error) {
-	ignorePatterns, err := ignore.LoadPatterns()
+const (
+	// recentCommits is how many commits are inspected to find recently changed files.
+	recentCommits = 20
+	// relevantScore and recentScore weight task relevance above recent changes when spending the budget.
+	relevantScore = 10
+	recentScore   = 5
+	// listDirectoryShare is the fraction of the structure budget a single list_directory call may use.
+	listDirectoryShare = 4
+)
+
+var (
+	wordPattern  = regexp.MustCompile(`[A-Za-z0-9]+`)
+	camelPattern = regexp.MustCompile(`[A-Z]?[a-z0-9]+|[A-Z]+(?:[A-Z][a-z]|$)`)
+	stopWords    = map[string]bool{
+		"the": true, "and": true, "for": true, "with": true, "that": true, "this": true, "from": true,
+		"into": true, "when": true, "should": true, "add": true, "fix": true, "use": true, "make": true,
+		"new": true, "all": true, "not": true, "are": true, "can": true, "file": true, "files": true,
+	}
+)
+
+// treeNode is a file or directory of the project. Directories carry totals over their whole subtree so
+// they can be summarized in one line when collapsed.
+type treeNode struct {
+	path     string
+	dir      bool
+	size     int64
+	files    int
+	depth    int
+	order    int
+	score    int
+	children []*treeNode
+
+	expanded bool
+	outline  string
+	// omitted counts trailing children left out because even the top-level listing exceeded the budget.
+	omitted int
+}
+
+func (n *treeNode) line() string {
+	switch {
+	case !n.dir:
+		return n.path + " (" + strconv.FormatInt(n.size, 10) + " bytes)\n"
+	case n.expanded:
+		return n.path + "/\n"
+	}
+	return fmt.Sprintf("%s/ — %d files, %s (collapsed, use list_directory to expand)\n", n.path, n.files, formatSize(n.size))
+}
+
+// getProjectStructure renders the project tree for the initial prompt within the configured token budget,
+// spending it first on paths relevant to the task and recently changed files.
+func (e *Executor) getProjectStructure(task string) (string, error) {
+	ignored, err := e.ignoreMatcher()
+	if err != nil {
+		return "", err
+	}
+	root, err := buildTree(".", ignored)
 	if err != nil {
 		return "", err
 	}
 
-	var structure strings.Builder
-	err = filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+	var recent []string
+	if repo, err := vcs.Open(); err == nil {
+		recent, _ = repo.RecentlyChanged(recentCommits)
+	}
+	scoreTree(root, taskTerms(task), recent)
+
+	e.layoutTree(root, e.structureBudget())
+	return renderTree(root), nil
+}
+
+// listDirectory renders the subtree at path the same way as the project structure, with a smaller budget
+// since it is requested repeatedly during a task.
+func (e *Executor) listDirectory(path string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(cwd, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the project", path)
+	}
+	info, err := os.Stat(rel)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", path)
+	}
+
+	ignored, err := e.ignoreMatcher()
+	if err != nil {
+		return "", err
+	}
+	root, err := buildTree(rel, ignored)
+	if err != nil {
+		return "", err
+	}
+	scoreTree(root, taskTerms(e.session.Task), nil)
+	e.layoutTree(root, e.structureBudget()/listDirectoryShare)
+	return renderTree(root), nil
+}
+
+func (e *Executor) structureBudget() int {
+	if e.cfg.StructureMaxTokens > 0 {
+		return e.cfg.StructureMaxTokens
+	}
+	return e.cfg.ContextWindow / 4
+}
+
+// buildTree walks the project from path, skipping ignored files.
+func buildTree(path string, ignored *ignore.Matcher) (*treeNode, error) {
+	nodes := make(map[string]*treeNode)
+	var root *treeNode
+	order := 0
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if info.IsDir() && info.Name() == ".git" {
-			return filepath.SkipDir
-		}
-
-		if util.IsIgnored(path, ignorePatterns) {
+		if ignored.Ignored(p, info.IsDir()) {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 
-		if info.IsDir() {
-			structure.WriteString(path + "/\n")
-		} else {
-			size := info.Size()
-			structure.WriteString(path + " (" + strconv.FormatInt(size, 10) + " bytes)\n")
-
-			if strings.HasSuffix(path, ".go") {
-				content, err := os.ReadFile(path)
-				if err == nil {
-					lines := strings.Split(string(content), "\n")
-					var snippet strings.Builder
-					for i, line := range lines {
-						if i >= e.cfg.SnippetMaxLines {
-							snippet.WriteString("// ... (truncated)\n")
-							break
-						}
-						snippet.WriteString(line + "\n")
-					}
-					structure.WriteString("```go\n")
-					structure.WriteString(snippet.String())
-					structure.WriteString("```\n")
+		node := &treeNode{path: p, dir: info.IsDir(), order: order}
+		order++
+		if !node.dir {
+			node.size = info.Size()
+			node.files = 1
+		}
+		nodes[p] = node
+		if root == nil {
+			root = node
+			return nil
+		}
+
+		parent := nodes[filepath.Dir(p)]
+		node.depth = parent.depth + 1
+		parent.children = append(parent.children, node)
+		if !node.dir {
+			for a := parent; a != nil; a = nodes[filepath.Dir(a.path)] {
+				a.size += node.size
+				a.files++
+				if a == root {
+					break
 				}
 			}
 		}
 		return nil
 	})
-	return structure.String(), err
+	if err == nil && root == nil {
+		err = fmt.Errorf("%s is excluded by ignore patterns", path)
+	}
+	return root, err
+}
+
+// taskTerms extracts lowercase words from the task that may appear in relevant paths,
+// splitting camelCase identifiers into their parts as well.
+func taskTerms(task string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(word string) {
+		word = strings.ToLower(word)
+		if len(word) < 3 || stopWords[word] || seen[word] {
+			return
+		}
+		seen[word] = true
+		terms = append(terms, word)
+	}
+	for _, word := range wordPattern.FindAllString(task, -1) {
+		add(word)
+		for _, part := range camelPattern.FindAllString(word, -1) {
+			add(part)
+		}
+	}
+	return terms
+}
+
+// scoreTree rates files by how many task terms their path contains and whether they changed recently.
+// Directories get the best score of their contents.
+func scoreTree(root *treeNode, terms, recent []string) {
+	recentSet := make(map[string]bool, len(recent))
+	for _, p := range recent {
+		recentSet[filepath.Clean(p)] = true
+	}
+
+	var walk func(n *treeNode) int
+	walk = func(n *treeNode) int {
+		if !n.dir {
+			lower := strings.ToLower(filepath.ToSlash(n.path))
+			for _, term := range terms {
+				if strings.Contains(lower, term) {
+					n.score += relevantScore
+				}
+			}
+			if recentSet[filepath.Clean(n.path)] {
+				n.score += recentScore
+			}
+			return n.score
+		}
+		for _, child := range n.children {
+			n.score = max(n.score, walk(child))
+		}
+		return n.score
+	}
+	walk(root)
+}
+
+// layoutTree decides which directories are expanded and which files get an outline so that the rendered
+// tree stays within budget tokens. Directories are expanded by relevance, then depth, then size, so deep
+// or large directories are the first to stay collapsed; outlines are added by relevance afterwards.
+func (e *Executor) layoutTree(root *treeNode, budget int) {
+	used := lineTokens(root.line())
+	root.expanded = true
+	queue := &dirQueue{}
+	expand := func(n *treeNode) {
+		for _, child := range n.children {
+			used += lineTokens(child.line())
+			if child.dir {
+				heap.Push(queue, child)
+			}
+		}
+	}
+
+	// The root is always listed, but a huge flat directory must not blow the budget on its own.
+	for i, child := range root.children {
+		cost := lineTokens(child.line())
+		if i > 0 && used+cost > budget {
+			root.omitted = len(root.children) - i
+			root.children = root.children[:i]
+			break
+		}
+		used += cost
+	}
+	for _, child := range root.children {
+		if child.dir {
+			heap.Push(queue, child)
+		}
+	}
+
+	for queue.Len() > 0 {
+		dir := heap.Pop(queue).(*treeNode)
+		delta := lineTokens(dir.path+"/\n") - lineTokens(dir.line())
+		cost := delta
+		for _, child := range dir.children {
+			cost += lineTokens(child.line())
+		}
+		if used+cost > budget {
+			continue
+		}
+		dir.expanded = true
+		used += delta
+		expand(dir)
+	}
+
+	var candidates []*treeNode
+	var visit func(n *treeNode)
+	visit = func(n *treeNode) {
+		if !n.dir {
+			// Outlines expose file contents, so files the policy denies reading are only listed.
+			if outline.Enabled(n.path, e.cfg.OutlineExtensions) && e.policy.ReadPath(n.path) != policy.Deny {
+				candidates = append(candidates, n)
+			}
+			return
+		}
+		if n.expanded {
+			for _, child := range n.children {
+				visit(child)
+			}
+		}
+	}
+	visit(root)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	for _, n := range candidates {
+		content, err := os.ReadFile(n.path)
+		if err != nil {
+			continue
+		}
+		x, _ := outline.For(n.path)
+		summary := e.outlineFile(x, n.path, content)
+		if summary == "" {
+			continue
+		}
+		block := "```" + x.Language + "\n" + summary + "```\n"
+		if cost := lineTokens(block); used+cost <= budget {
+			n.outline = block
+			used += cost
+		}
+	}
+}
+
+func renderTree(root *treeNode) string {
+	var structure strings.Builder
+	var render func(n *treeNode)
+	render = func(n *treeNode) {
+		structure.WriteString(n.line())
+		structure.WriteString(n.outline)
+		if n.dir && n.expanded {
+			for _, child := range n.children {
+				render(child)
+			}
+			if n.omitted > 0 {
+				fmt.Fprintf(&structure, "%s/... (%d more entries not shown)\n", n.path, n.omitted)
+			}
+		}
+	}
+	render(root)
+	return structure.String()
+}
+
+func lineTokens(text string) int {
+	return len(text)/charsPerToken + 1
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// dirQueue orders collapsed directories by expansion priority.
+type dirQueue []*treeNode
+
+func (q dirQueue) Len() int { return len(q) }
+
+func (q dirQueue) Less(i, j int) bool {
+	a, b := q[i], q[j]
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	if a.depth != b.depth {
+		return a.depth < b.depth
+	}
+	if a.files != b.files {
+		return a.files < b.files
+	}
+	return a.order < b.order
+}
+
+func (q dirQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *dirQueue) Push(x any) { *q = append(*q, x.(*treeNode)) }
+
+func (q *dirQueue) Pop() any {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
+
+// outlineFile summarizes a file as its symbol outline, falling back to the first lines of the file
+// when it does not parse, e.g. while the model is in the middle of editing it.
+func (e *Executor) outlineFile(x outline.Extractor, path string, content []byte) string {
+	if len(bytes.TrimSpace(content)) == 0 {
+		return ""
+	}
+	lines, err := x.Extract(path, content, e.cfg.OutlineDepth)
+	if err != nil {
+		lines = strings.Split(string(content), "\n")
+	}
+
+	var snippet strings.Builder
+	for i, line := range lines {
+		if i >= e.cfg.SnippetMaxLines {
+			snippet.WriteString("// ... (truncated)\n")
+			break
+		}
+		snippet.WriteString(line + "\n")
+	}
+	return snippet.String()
 }
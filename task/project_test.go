@@ -0,0 +1,67 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rofleksey/dwight/session"
+	"github.com/sashabaranov/go-openai"
+)
+
+// setupPolicyProject creates a project whose policy denies reading secret/.
+func setupPolicyProject(t *testing.T) *Executor {
+	t.Helper()
+	setupProject(t)
+	for path, content := range map[string]string{
+		".dwight/policy.yaml": "read:\n  - pattern: \"secret/**\"\n    action: deny\n",
+		"pkg/api.go":          "package pkg\n\nfunc PublicHelper() {}\n",
+		"secret/keys.go":      "package secret\n\nfunc SigningKeyLoader() {}\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	executor, _, _ := newTestExecutor(t, testConfig())
+	executor.session = session.New("add a helper")
+	return executor
+}
+
+func TestProjectStructureSkipsOutlinesOfDeniedFiles(t *testing.T) {
+	executor := setupPolicyProject(t)
+
+	structure, err := executor.getProjectStructure("add a helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(structure, "PublicHelper") {
+		t.Errorf("structure has no outline of pkg/api.go:\n%s", structure)
+	}
+	if !strings.Contains(structure, "secret/keys.go") || strings.Contains(structure, "SigningKeyLoader") {
+		t.Errorf("structure should list secret/keys.go without its outline:\n%s", structure)
+	}
+}
+
+func TestListDirectoryFollowsReadPolicy(t *testing.T) {
+	executor := setupPolicyProject(t)
+
+	list := func(path string) string {
+		var messages []openai.ChatCompletionMessage
+		toolCall := openai.ToolCall{ID: "call_1", Function: openai.FunctionCall{Name: "list_directory", Arguments: `{"path": "` + path + `"}`}}
+		if err := executor.handleListDirectory(toolCall, &messages); err != nil {
+			t.Fatal(err)
+		}
+		return messages[len(messages)-1].Content
+	}
+
+	if got := list("secret"); !strings.Contains(got, "denied by policy") {
+		t.Errorf("listing secret = %q, want it denied", got)
+	}
+	if got := list("."); strings.Contains(got, "SigningKeyLoader") || !strings.Contains(got, "PublicHelper") {
+		t.Errorf("listing . = %q, want only the outline of pkg/api.go", got)
+	}
+}
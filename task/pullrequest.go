@@ -0,0 +1,198 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rofleksey/dwight/bitbucket"
+	"github.com/rofleksey/dwight/prompts"
+	"github.com/rofleksey/dwight/vcs"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	pullRequestRemote  = "origin"
+	pullRequestMaxDiff = 30000
+)
+
+var branchNameSanitizer = regexp.MustCompile(`[^a-z0-9]+`)
+
+type pullRequestText struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// CreatePullRequest commits the task's changes on a new branch, pushes it and opens
+// a Bitbucket Server pull request against the branch that was checked out before.
+func (e *Executor) CreatePullRequest() (*bitbucket.PullRequest, error) {
+	if e.cfg.BitbucketHost == "" || e.cfg.BitbucketToken == "" {
+		return nil, errors.New("bitbucket_host and bitbucket_token must be set in config to create pull requests")
+	}
+
+	repo, err := vcs.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	remoteURL, err := repo.RemoteURL(pullRequestRemote)
+	if err != nil {
+		return nil, err
+	}
+	bbRepo, err := bitbucket.ParseRepository(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	baseBranch, err := repo.CurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := e.changedRepositoryPaths(repo)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := repo.Changes(paths)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, errors.New("no changes to submit")
+	}
+	paths = paths[:0]
+	for _, change := range changes {
+		paths = append(paths, change.Path)
+	}
+
+	e.infof("Generating pull request description...")
+	text, err := e.describeChanges(changes)
+	if err != nil {
+		return nil, fmt.Errorf("error generating pull request description: %w", err)
+	}
+
+	branch := pullRequestBranch(text.Title)
+	e.infof("Committing changes to branch %s", branch)
+	if err := repo.CreateBranch(branch); err != nil {
+		return nil, fmt.Errorf("error creating branch %s: %w", branch, err)
+	}
+	if _, err := repo.CommitPaths(text.Title+"\n\n"+text.Description, paths); err != nil {
+		return nil, returnToBranch(repo, baseBranch, err, "")
+	}
+
+	e.infof("Pushing %s to %s", branch, pullRequestRemote)
+	if err := repo.Push(pullRequestRemote, branch, e.cfg.BitbucketToken); err != nil {
+		return nil, returnToBranch(repo, baseBranch, err, fmt.Sprintf("the changes are committed on branch %s, which was not pushed", branch))
+	}
+
+	client := bitbucket.NewClient(e.cfg.BitbucketHost, e.cfg.BitbucketToken)
+	pr, err := client.CreatePullRequest(context.Background(), bbRepo, bitbucket.PullRequestInput{
+		Title:        text.Title,
+		Description:  text.Description,
+		SourceBranch: branch,
+		TargetBranch: baseBranch,
+	})
+	if err != nil {
+		return nil, returnToBranch(repo, baseBranch, err, fmt.Sprintf("branch %s was pushed, open the pull request manually", branch))
+	}
+	return pr, nil
+}
+
+// returnToBranch switches back to the branch the pull request flow started on after it failed, keeping
+// the changes in the working tree, and describes the resulting state in the error.
+func returnToBranch(repo *vcs.Repository, branch string, cause error, state string) error {
+	if err := repo.ReturnToBranch(branch); err != nil {
+		return fmt.Errorf("%w; switching back to %s failed too: %v", cause, branch, err)
+	}
+	if state == "" {
+		return fmt.Errorf("%w; switched back to %s, the changes are still in the working tree", cause, branch)
+	}
+	return fmt.Errorf("%w; switched back to %s, the changes are still in the working tree and %s", cause, branch, state)
+}
+
+// changedRepositoryPaths returns the paths the task changed, relative to the repository root. Moves
+// contribute both their source and destination.
+func (e *Executor) changedRepositoryPaths(repo *vcs.Repository) ([]string, error) {
+	var paths []string
+	for _, change := range mergeChanges(e.session.Changes) {
+		for _, path := range []string{change.From, change.Path} {
+			if path == "" {
+				continue
+			}
+			rel, err := repo.RelativePath(path)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, rel)
+		}
+	}
+	return paths, nil
+}
+
+func (e *Executor) describeChanges(changes []vcs.Change) (pullRequestText, error) {
+	var diff strings.Builder
+	for _, change := range changes {
+		fmt.Fprintf(&diff, "%s (%s)\n", change.Path, change.Status)
+	}
+	diff.WriteString("\n")
+	for _, change := range changes {
+		if diff.Len()+len(change.Diff) > pullRequestMaxDiff {
+			diff.WriteString("... (diff truncated)\n")
+			break
+		}
+		diff.WriteString(change.Diff)
+	}
+
+	response, err := e.client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
+		Model: e.cfg.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: prompts.PullRequestSP,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf("Task: %s\n\nChanged files and diff:\n%s", e.session.Task, e.redactText(diff.String(), "the pull request diff")),
+			},
+		},
+	}, nil)
+	if err != nil {
+		return pullRequestText{}, err
+	}
+	e.recordUsage(response.Usage)
+	e.saveSession()
+	if len(response.Choices) == 0 {
+		return pullRequestText{}, errors.New("no choices returned by the model")
+	}
+
+	content := response.Choices[0].Message.Content
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return pullRequestText{}, fmt.Errorf("model did not return JSON: %s", content)
+	}
+
+	var text pullRequestText
+	if err := json.Unmarshal([]byte(content[start:end+1]), &text); err != nil {
+		return pullRequestText{}, fmt.Errorf("error parsing model response: %w", err)
+	}
+	text.Title = strings.TrimSpace(text.Title)
+	if text.Title == "" {
+		text.Title = taskSummary(e.session.Task)
+	}
+	return text, nil
+}
+
+func pullRequestBranch(title string) string {
+	slug := strings.Trim(branchNameSanitizer.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if len(slug) > 40 {
+		slug = strings.Trim(slug[:40], "-")
+	}
+	if slug == "" {
+		slug = "task"
+	}
+	return fmt.Sprintf("dwight/%s-%s", slug, time.Now().Format("20060102150405"))
+}
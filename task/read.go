@@ -0,0 +1,138 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// aroundBefore and aroundAfter frame the lines returned for an "around" symbol read;
+	// definitions mostly extend below the matched line.
+	aroundBefore = 10
+	aroundAfter  = 60
+
+	rangeKeySeparator = " ("
+)
+
+// fileRequest is one entry of get_file_contents. Plain strings are accepted as whole-file reads,
+// which is the original form of the tool and still appears in older sessions.
+type fileRequest struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Around    string `json:"around"`
+}
+
+func (f *fileRequest) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*f = fileRequest{Path: path}
+		return nil
+	}
+
+	type plain fileRequest
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*f = fileRequest(p)
+	return nil
+}
+
+// key identifies the result of this request in the tool response.
+func (f fileRequest) key() string {
+	switch {
+	case f.Around != "":
+		return fmt.Sprintf("%s%saround %s)", f.Path, rangeKeySeparator, f.Around)
+	case f.EndLine > 0:
+		return fmt.Sprintf("%s%slines %d-%d)", f.Path, rangeKeySeparator, max(f.StartLine, 1), f.EndLine)
+	case f.StartLine > 0:
+		return fmt.Sprintf("%s%slines %d-end)", f.Path, rangeKeySeparator, f.StartLine)
+	}
+	return f.Path
+}
+
+// readKeyPath returns the file path of a get_file_contents result key.
+func readKeyPath(key string) string {
+	if i := strings.LastIndex(key, rangeKeySeparator); i >= 0 && strings.HasSuffix(key, ")") {
+		return key[:i]
+	}
+	return key
+}
+
+// renderLines returns the requested lines of content prefixed with their line numbers, preceded by a
+// header with the total line count. Output beyond maxBytes is cut off with a notice telling the model
+// how to continue.
+func renderLines(content string, req fileRequest, maxBytes int) (string, error) {
+	lines := strings.Split(content, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	total := len(lines)
+
+	start, end := 1, total
+	switch {
+	case req.Around != "":
+		line, ok := findSymbol(lines, req.Around)
+		if !ok {
+			return "", fmt.Errorf("symbol %q not found in file", req.Around)
+		}
+		start, end = max(line-aroundBefore, 1), min(line+aroundAfter, total)
+	case req.StartLine > 0 || req.EndLine > 0:
+		start = max(req.StartLine, 1)
+		if req.EndLine > 0 {
+			end = min(req.EndLine, total)
+		}
+	}
+	if total == 0 {
+		return "[empty file]", nil
+	}
+	if start > total {
+		return "", fmt.Errorf("start_line %d is beyond the end of the file (%d lines)", start, total)
+	}
+	if start > end {
+		return "", fmt.Errorf("start_line %d is after end_line %d", start, end)
+	}
+
+	var body strings.Builder
+	last := start - 1
+	for i := start; i <= end; i++ {
+		line := fmt.Sprintf("%6d\t%s\n", i, lines[i-1])
+		if body.Len()+len(line) > maxBytes && i > start {
+			break
+		}
+		body.WriteString(line)
+		last = i
+	}
+
+	header := fmt.Sprintf("[lines %d-%d of %d]\n", start, last, total)
+	if start == 1 && last == total {
+		header = fmt.Sprintf("[%d lines]\n", total)
+	}
+	if last < end {
+		return header + body.String() + fmt.Sprintf("[truncated: output limit of %d bytes reached, continue with start_line=%d]\n", maxBytes, last+1), nil
+	}
+	return header + body.String(), nil
+}
+
+// findSymbol returns the 1-based line of the most likely definition of symbol, falling back to its first use.
+func findSymbol(lines []string, symbol string) (int, bool) {
+	word := regexp.MustCompile(`\b` + regexp.QuoteMeta(symbol) + `\b`)
+	definition := regexp.MustCompile(`^\s*(export\s+)?(async\s+)?(func|type|var|const|class|def|interface|struct|enum|message|service|function|let|CREATE\s+\w+)\b.*\b` + regexp.QuoteMeta(symbol) + `\b`)
+
+	first := 0
+	for i, line := range lines {
+		if !word.MatchString(line) {
+			continue
+		}
+		if definition.MatchString(line) {
+			return i + 1, true
+		}
+		if first == 0 {
+			first = i + 1
+		}
+	}
+	return first, first > 0
+}
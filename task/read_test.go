@@ -0,0 +1,166 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileRequestUnmarshalJSON(t *testing.T) {
+	var args struct {
+		Files []fileRequest `json:"files"`
+	}
+	// Older sessions list plain paths, newer ones objects; both may be mixed.
+	data := `{"files":["a.go",{"path":"b.go","start_line":10,"end_line":20},{"path":"c.go","around":"Run"}]}`
+	if err := json.Unmarshal([]byte(data), &args); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []fileRequest{
+		{Path: "a.go"},
+		{Path: "b.go", StartLine: 10, EndLine: 20},
+		{Path: "c.go", Around: "Run"},
+	}
+	if !reflect.DeepEqual(args.Files, want) {
+		t.Errorf("files = %+v, want %+v", args.Files, want)
+	}
+
+	wantKeys := []string{"a.go", "b.go (lines 10-20)", "c.go (around Run)"}
+	for i, f := range args.Files {
+		if got := f.key(); got != wantKeys[i] {
+			t.Errorf("key() = %q, want %q", got, wantKeys[i])
+		}
+		if got := readKeyPath(f.key()); got != f.Path {
+			t.Errorf("readKeyPath(%q) = %q, want %q", f.key(), got, f.Path)
+		}
+	}
+
+	var invalid fileRequest
+	if err := json.Unmarshal([]byte(`42`), &invalid); err == nil {
+		t.Error("a number was accepted as a file request")
+	}
+}
+
+// numberedLines renders lines from to to of fileContent(n) the way renderLines prints them.
+func numberedLines(from, to int) string {
+	var b strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&b, "%6d\tline %d\n", i, i)
+	}
+	return b.String()
+}
+
+func fileContent(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
+
+func TestRenderLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		req      fileRequest
+		maxBytes int
+		want     string
+		wantErr  string
+	}{
+		{name: "whole file", content: fileContent(3), want: "[3 lines]\n" + numberedLines(1, 3)},
+		{name: "without trailing newline", content: "line 1\nline 2", want: "[2 lines]\n" + numberedLines(1, 2)},
+		{name: "empty file", content: "", want: "[empty file]"},
+		{name: "range", content: fileContent(10), req: fileRequest{StartLine: 3, EndLine: 5}, want: "[lines 3-5 of 10]\n" + numberedLines(3, 5)},
+		{name: "start only", content: fileContent(10), req: fileRequest{StartLine: 9}, want: "[lines 9-10 of 10]\n" + numberedLines(9, 10)},
+		{name: "end only", content: fileContent(10), req: fileRequest{EndLine: 2}, want: "[lines 1-2 of 10]\n" + numberedLines(1, 2)},
+		{name: "end beyond the file", content: fileContent(4), req: fileRequest{StartLine: 3, EndLine: 100}, want: "[lines 3-4 of 4]\n" + numberedLines(3, 4)},
+		{name: "start beyond the file", content: fileContent(4), req: fileRequest{StartLine: 5}, wantErr: "start_line 5 is beyond the end of the file (4 lines)"},
+		{name: "start after end", content: fileContent(10), req: fileRequest{StartLine: 5, EndLine: 4}, wantErr: "start_line 5 is after end_line 4"},
+		{
+			name:     "truncated at the byte limit",
+			content:  fileContent(10),
+			req:      fileRequest{StartLine: 2},
+			maxBytes: 3 * len(numberedLines(2, 2)),
+			want:     "[lines 2-4 of 10]\n" + numberedLines(2, 4) + "[truncated: output limit of 42 bytes reached, continue with start_line=5]\n",
+		},
+		{
+			// The first line is always returned, so reads make progress even with tiny limits.
+			name:     "first line exceeds the limit",
+			content:  fileContent(3),
+			maxBytes: 1,
+			want:     "[lines 1-1 of 3]\n" + numberedLines(1, 1) + "[truncated: output limit of 1 bytes reached, continue with start_line=2]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.maxBytes == 0 {
+				tt.maxBytes = 100 * 1024
+			}
+			got, err := renderLines(tt.content, tt.req, tt.maxBytes)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("renderLines() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("renderLines() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderLinesAround(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(fileContent(100), "\n"), "\n")
+	lines[14] = "\trunner := Run()"
+	lines[49] = "func Run() error {"
+	content := strings.Join(lines, "\n") + "\n"
+
+	got, err := renderLines(content, fileRequest{Around: "Run"}, 100*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header := "[lines 40-100 of 100]\n"; !strings.HasPrefix(got, header) {
+		t.Errorf("renderLines(around Run) starts with %q, want %q around the definition on line 50", strings.SplitN(got, "\n", 2)[0], header)
+	}
+	if !strings.Contains(got, "    50\tfunc Run() error {\n") {
+		t.Errorf("definition is missing from the output:\n%s", got)
+	}
+
+	if _, err := renderLines(content, fileRequest{Around: "Missing"}, 100*1024); err == nil || !strings.Contains(err.Error(), `symbol "Missing" not found`) {
+		t.Errorf("renderLines(around Missing) error = %v", err)
+	}
+}
+
+func TestFindSymbol(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []string
+		symbol string
+		want   int
+		ok     bool
+	}{
+		{name: "go function after a use", lines: []string{"x := Parse(s)", "", "func Parse(s string) error {"}, symbol: "Parse", want: 3, ok: true},
+		{name: "go method", lines: []string{"func (p *Parser) Parse() error {"}, symbol: "Parse", want: 1, ok: true},
+		{name: "exported async js function", lines: []string{"await load()", "export async function load() {"}, symbol: "load", want: 2, ok: true},
+		{name: "python class", lines: []string{"    user = User()", "class User(Base):"}, symbol: "User", want: 2, ok: true},
+		{name: "sql table", lines: []string{"SELECT * FROM users;", "CREATE TABLE users ("}, symbol: "users", want: 2, ok: true},
+		{name: "proto message", lines: []string{"  Request request = 1;", "message Request {"}, symbol: "Request", want: 2, ok: true},
+		{name: "falls back to the first use", lines: []string{"a", "call(Helper)", "Helper()"}, symbol: "Helper", want: 2, ok: true},
+		{name: "whole words only", lines: []string{"func RunAll() {}", "func rerun() {}"}, symbol: "Run", want: 0, ok: false},
+		{name: "regexp characters are literal", lines: []string{"aab", "x = a+b"}, symbol: "a+b", want: 2, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := findSymbol(tt.lines, tt.symbol)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("findSymbol(%q) = %d, %v, want %d, %v", tt.symbol, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
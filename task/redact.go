@@ -0,0 +1,110 @@
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/rofleksey/dwight/config"
+	"github.com/rofleksey/dwight/redact"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newRedactor returns nil when redaction is disabled. Custom patterns are validated by config.LoadConfig.
+func newRedactor(cfg *config.Config) *redact.Redactor {
+	if !cfg.RedactSecrets {
+		return nil
+	}
+	custom := make([]redact.Rule, 0, len(cfg.RedactPatterns))
+	for _, pattern := range cfg.RedactPatterns {
+		custom = append(custom, redact.Rule{Name: pattern.Name, Regex: regexp.MustCompile(pattern.Regex)})
+	}
+	return redact.New(custom, cfg.RedactEntropy)
+}
+
+// redactText removes secrets from text that is about to be sent to the model and reports what was removed.
+func (e *Executor) redactText(text, source string) string {
+	if e.redactor == nil {
+		return text
+	}
+	redacted, report := e.redactor.Redact(text)
+	e.recordRedactions(report, source)
+	return redacted
+}
+
+// redactToolResults removes secrets from tool messages. JSON results are redacted value by value,
+// since escaping inside JSON strings would hide quotes and line starts from the patterns.
+func (e *Executor) redactToolResults(messages []openai.ChatCompletionMessage, source string) {
+	if e.redactor == nil {
+		return
+	}
+	for i := range messages {
+		if messages[i].Role != openai.ChatMessageRoleTool {
+			continue
+		}
+
+		report := redact.Report{}
+		decoder := json.NewDecoder(bytes.NewReader([]byte(messages[i].Content)))
+		decoder.UseNumber()
+		var value any
+		if err := decoder.Decode(&value); err == nil && !decoder.More() {
+			value = redactValue(e.redactor, value, report)
+			if report.Total() > 0 {
+				if data, err := json.Marshal(value); err == nil {
+					messages[i].Content = string(data)
+				}
+			}
+		} else {
+			var r redact.Report
+			messages[i].Content, r = e.redactor.Redact(messages[i].Content)
+			report = r
+		}
+		e.recordRedactions(report, source)
+	}
+}
+
+func redactValue(r *redact.Redactor, value any, report redact.Report) any {
+	switch v := value.(type) {
+	case string:
+		redacted, found := r.Redact(v)
+		for kind, n := range found {
+			report[kind] += n
+		}
+		return redacted
+	case map[string]any:
+		for key, item := range v {
+			v[key] = redactValue(r, item, report)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactValue(r, item, report)
+		}
+	}
+	return value
+}
+
+func (e *Executor) recordRedactions(report redact.Report, source string) {
+	if report.Total() == 0 {
+		return
+	}
+	e.infof("Redacted %d secret(s) from %s before sending it to the model: %s", report.Total(), source, report)
+	if e.session == nil {
+		return
+	}
+	if e.session.Redactions == nil {
+		e.session.Redactions = make(map[string]int)
+	}
+	for kind, n := range report {
+		e.session.Redactions[kind] += n
+	}
+}
+
+// checkPlaceholders refuses replacing before with after if after introduces redaction placeholders,
+// which would overwrite the real secrets with them.
+func checkPlaceholders(before, after string) error {
+	if redact.Placeholders(after) > redact.Placeholders(before) {
+		return fmt.Errorf("content contains redaction placeholders; secrets were hidden from you, so change only the surrounding text with edit_file and leave lines containing secrets untouched")
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rofleksey/dwight/redact"
+	"github.com/rofleksey/dwight/session"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Exit codes of dwight runs. They are stable, so automation can gate on them.
+const (
+	ExitCompleted      = 0
+	ExitFailed         = 1
+	ExitGaveUp         = 2
+	ExitInputRequired  = 3
+	ExitAborted        = 4
+	ExitBudgetExceeded = 5
+	ExitLimitReached   = 6
+	ExitProviderError  = 7
+	ExitPartial        = 8
+)
+
+// ExitCode maps the final status of a session to the exit code of the run.
+func ExitCode(status string) int {
+	switch status {
+	case session.StatusCompleted:
+		return ExitCompleted
+	case session.StatusPartial:
+		return ExitPartial
+	case session.StatusStopped, session.StatusGaveUp:
+		return ExitGaveUp
+	case session.StatusInputRequired:
+		return ExitInputRequired
+	case session.StatusAborted:
+		return ExitAborted
+	case session.StatusBudgetExceeded:
+		return ExitBudgetExceeded
+	case session.StatusLimitReached:
+		return ExitLimitReached
+	case session.StatusProviderError:
+		return ExitProviderError
+	default:
+		return ExitFailed
+	}
+}
+
+// Report summarizes the outcome of a task run.
+type Report struct {
+	Session      string               `json:"session"`
+	Task         string               `json:"task"`
+	Status       string               `json:"status"`
+	ExitCode     int                  `json:"exit_code"`
+	Error        string               `json:"error,omitempty"`
+	Summary      string               `json:"summary,omitempty"`
+	Completion   *session.Completion  `json:"completion,omitempty"`
+	Changes      []session.FileChange `json:"changes"`
+	Commands     []session.CommandRun `json:"commands"`
+	Usage        session.Usage        `json:"usage"`
+	Redactions   map[string]int       `json:"redactions,omitempty"`
+	CheckpointID string               `json:"checkpoint_id,omitempty"`
+}
+
+// ExitCode returns the exit code for the last executed or resumed task.
+func (e *Executor) ExitCode() int {
+	if e.session == nil {
+		return ExitFailed
+	}
+	return ExitCode(e.session.Status)
+}
+
+// Report returns the report of the last executed or resumed task, or nil if none was started.
+func (e *Executor) Report() *Report {
+	if e.session == nil {
+		return nil
+	}
+	s := e.session
+	summary := completionSummary(s.Messages)
+	if s.Completion != nil {
+		summary = s.Completion.Summary
+	}
+	return &Report{
+		Session:      s.ID,
+		Task:         s.Task,
+		Status:       s.Status,
+		ExitCode:     ExitCode(s.Status),
+		Error:        s.Error,
+		Summary:      summary,
+		Completion:   s.Completion,
+		Changes:      mergeChanges(s.Changes),
+		Commands:     append([]session.CommandRun{}, s.Commands...),
+		Usage:        s.Usage,
+		Redactions:   s.Redactions,
+		CheckpointID: s.CheckpointID,
+	}
+}
+
+// WriteReport writes the report of the last task to path as JSON.
+func (e *Executor) WriteReport(path string) error {
+	report := e.Report()
+	if report == nil {
+		return fmt.Errorf("no task was started")
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Task report: %s (exit code %d)\n", r.Status, r.ExitCode)
+	if r.Error != "" {
+		fmt.Fprintf(&b, "Error: %s\n", r.Error)
+	}
+	if r.Summary != "" {
+		fmt.Fprintf(&b, "Summary: %s\n", r.Summary)
+	}
+	if c := r.Completion; c != nil {
+		verified := ""
+		if c.Verified {
+			verified = " (verification command passed)"
+		}
+		fmt.Fprintf(&b, "Verification: %s%s\n", c.Verification, verified)
+		if len(c.OpenIssues) > 0 {
+			b.WriteString("Open issues:\n")
+			for _, issue := range c.OpenIssues {
+				fmt.Fprintf(&b, "  - %s\n", issue)
+			}
+		}
+	}
+
+	if len(r.Changes) == 0 {
+		b.WriteString("Files changed: none\n")
+	} else {
+		b.WriteString("Files changed:\n")
+		for _, c := range r.Changes {
+			if c.Kind == session.ChangeMoved {
+				fmt.Fprintf(&b, "  %-8s %s -> %s\n", c.Kind, c.From, c.Path)
+			} else {
+				fmt.Fprintf(&b, "  %-8s %s\n", c.Kind, c.Path)
+			}
+		}
+	}
+
+	if len(r.Commands) == 0 {
+		b.WriteString("Commands run: none\n")
+	} else {
+		b.WriteString("Commands run:\n")
+		for _, c := range r.Commands {
+			status := fmt.Sprintf("exit %d", c.ExitCode)
+			if c.TimedOut {
+				status = "timed out"
+			}
+			fmt.Fprintf(&b, "  [%s] %s\n", status, strings.ReplaceAll(c.Command, "\n", " "))
+		}
+	}
+
+	fmt.Fprintf(&b, "Usage: %d requests, %s", r.Usage.Requests, formatUsage(r.Usage))
+	if len(r.Redactions) > 0 {
+		report := redact.Report(r.Redactions)
+		fmt.Fprintf(&b, "\nRedacted secrets: %d (%s)", report.Total(), report)
+	}
+	if r.CheckpointID != "" && len(r.Changes) > 0 {
+		fmt.Fprintf(&b, "\nUndo all changes with 'dwight undo %s'", r.CheckpointID)
+	}
+	return b.String()
+}
+
+func (e *Executor) recordChange(kind, path, from string) {
+	e.session.Changes = append(e.session.Changes, session.FileChange{Path: filepath.ToSlash(path), Kind: kind, From: filepath.ToSlash(from)})
+}
+
+func (e *Executor) recordCommand(command string, exitCode int, timedOut bool) {
+	e.session.Commands = append(e.session.Commands, session.CommandRun{Command: command, ExitCode: exitCode, TimedOut: timedOut})
+}
+
+// completionSummary returns the last text the model wrote, for sessions that did not end with task_complete.
+func completionSummary(messages []openai.ChatCompletionMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		if msg.Role == openai.ChatMessageRoleAssistant && strings.TrimSpace(msg.Content) != "" {
+			return strings.TrimSpace(msg.Content)
+		}
+	}
+	return ""
+}
+
+// mergeChanges collapses repeated changes of the same file into its net change, e.g. a file that was
+// created and then edited is reported as created, and a file that was moved and then edited as moved.
+func mergeChanges(changes []session.FileChange) []session.FileChange {
+	var merged []session.FileChange
+	index := make(map[string]int)
+	for _, c := range changes {
+		if c.Kind == session.ChangeMoved {
+			c = mergeMove(merged, index, c)
+		}
+		i, seen := index[c.Path]
+		if !seen {
+			index[c.Path] = len(merged)
+			merged = append(merged, c)
+			continue
+		}
+
+		net := netChange(merged[i], c)
+		delete(index, c.Path)
+		// Deleting a moved file may land on its original path, which can have been recreated meanwhile.
+		if k, ok := index[net.Path]; ok && net.Kind != "" {
+			merged[k] = netChange(net, merged[k])
+			net.Kind = ""
+		}
+		merged[i] = net
+		if net.Kind != "" {
+			index[net.Path] = i
+		}
+	}
+
+	result := make([]session.FileChange, 0, len(merged))
+	for _, c := range merged {
+		if c.Kind != "" {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// mergeMove folds the earlier change of the source path of move c into it and drops that change.
+func mergeMove(merged []session.FileChange, index map[string]int, c session.FileChange) session.FileChange {
+	j, ok := index[c.From]
+	if !ok {
+		return c
+	}
+	delete(index, c.From)
+	prev := merged[j]
+	merged[j].Kind = ""
+
+	switch prev.Kind {
+	case session.ChangeCreated:
+		// A file created in this task and moved afterwards is simply created at its new path.
+		return session.FileChange{Path: c.Path, Kind: session.ChangeCreated}
+	case session.ChangeMoved:
+		// Chained moves are reported from the original path, a file moved back is only modified.
+		if prev.From == c.Path {
+			return session.FileChange{Path: c.Path, Kind: session.ChangeModified}
+		}
+		c.From = prev.From
+	}
+	return c
+}
+
+// netChange combines two consecutive changes of the same path. An empty Kind means nothing changed.
+func netChange(prev, next session.FileChange) session.FileChange {
+	switch {
+	case prev.Kind == session.ChangeCreated && next.Kind == session.ChangeDeleted:
+		return session.FileChange{}
+	case prev.Kind == session.ChangeCreated && next.Kind == session.ChangeModified,
+		prev.Kind == session.ChangeMoved && next.Kind == session.ChangeModified:
+		return prev
+	case prev.Kind == session.ChangeMoved && next.Kind == session.ChangeDeleted:
+		// Deleting a moved file deletes it at its original path.
+		return session.FileChange{Path: prev.From, Kind: session.ChangeDeleted}
+	case prev.Kind == session.ChangeDeleted && next.Kind == session.ChangeCreated:
+		return session.FileChange{Path: prev.Path, Kind: session.ChangeModified}
+	}
+	return next
+}
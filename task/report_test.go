@@ -0,0 +1,130 @@
+package task
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rofleksey/dwight/session"
+)
+
+func created(path string) session.FileChange {
+	return session.FileChange{Path: path, Kind: session.ChangeCreated}
+}
+
+func modified(path string) session.FileChange {
+	return session.FileChange{Path: path, Kind: session.ChangeModified}
+}
+
+func deleted(path string) session.FileChange {
+	return session.FileChange{Path: path, Kind: session.ChangeDeleted}
+}
+
+func moved(from, path string) session.FileChange {
+	return session.FileChange{Path: path, Kind: session.ChangeMoved, From: from}
+}
+
+func TestMergeChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes []session.FileChange
+		want    []session.FileChange
+	}{
+		{
+			name:    "distinct files",
+			changes: []session.FileChange{modified("a"), created("b"), deleted("c")},
+			want:    []session.FileChange{modified("a"), created("b"), deleted("c")},
+		},
+		{
+			name:    "created then modified",
+			changes: []session.FileChange{created("a"), modified("a"), modified("a")},
+			want:    []session.FileChange{created("a")},
+		},
+		{
+			name:    "created then deleted",
+			changes: []session.FileChange{created("a"), modified("b"), deleted("a")},
+			want:    []session.FileChange{modified("b")},
+		},
+		{
+			name:    "deleted then created",
+			changes: []session.FileChange{deleted("a"), created("a")},
+			want:    []session.FileChange{modified("a")},
+		},
+		{
+			name:    "modified then moved",
+			changes: []session.FileChange{modified("a"), moved("a", "b")},
+			want:    []session.FileChange{moved("a", "b")},
+		},
+		{
+			name:    "created then moved",
+			changes: []session.FileChange{created("a"), moved("a", "b")},
+			want:    []session.FileChange{created("b")},
+		},
+		{
+			name:    "chained moves keep the original path",
+			changes: []session.FileChange{moved("a", "b"), moved("b", "c")},
+			want:    []session.FileChange{moved("a", "c")},
+		},
+		{
+			name:    "moved back",
+			changes: []session.FileChange{moved("a", "b"), moved("b", "a")},
+			want:    []session.FileChange{modified("a")},
+		},
+		{
+			name:    "moved then modified",
+			changes: []session.FileChange{moved("a", "b"), modified("b")},
+			want:    []session.FileChange{moved("a", "b")},
+		},
+		{
+			name:    "chained moves then modified",
+			changes: []session.FileChange{moved("a", "b"), modified("b"), moved("b", "c"), modified("c")},
+			want:    []session.FileChange{moved("a", "c")},
+		},
+		{
+			name:    "moved then deleted",
+			changes: []session.FileChange{moved("a", "b"), deleted("b")},
+			want:    []session.FileChange{deleted("a")},
+		},
+		{
+			name:    "moved, original path recreated, then deleted",
+			changes: []session.FileChange{moved("a", "b"), created("a"), deleted("b")},
+			want:    []session.FileChange{modified("a")},
+		},
+		{
+			name:    "moved then original path recreated",
+			changes: []session.FileChange{moved("a", "b"), created("a")},
+			want:    []session.FileChange{moved("a", "b"), created("a")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeChanges(tt.changes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeChanges() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		status string
+		want   int
+	}{
+		{status: session.StatusCompleted, want: ExitCompleted},
+		{status: session.StatusPartial, want: ExitPartial},
+		{status: session.StatusStopped, want: ExitGaveUp},
+		// task_complete with status failed means the model gave up, not that dwight itself failed.
+		{status: session.StatusGaveUp, want: ExitGaveUp},
+		{status: session.StatusFailed, want: ExitFailed},
+		{status: session.StatusInputRequired, want: ExitInputRequired},
+		{status: session.StatusAborted, want: ExitAborted},
+		{status: session.StatusBudgetExceeded, want: ExitBudgetExceeded},
+		{status: session.StatusLimitReached, want: ExitLimitReached},
+		{status: session.StatusProviderError, want: ExitProviderError},
+		{status: session.StatusRunning, want: ExitFailed},
+	}
+	for _, tt := range tests {
+		if got := ExitCode(tt.status); got != tt.want {
+			t.Errorf("ExitCode(%s) = %d, want %d", tt.status, got, tt.want)
+		}
+	}
+}
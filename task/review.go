@@ -0,0 +1,69 @@
+package task
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/rofleksey/dwight/event"
+	"github.com/rofleksey/dwight/policy"
+	"github.com/rofleksey/dwight/util"
+)
+
+// reviewByHunk reports whether a change to an existing file should be reviewed hunk by hunk instead of
+// being confirmed as a whole. Changes decided by the policy or by --yes, and non-interactive runs, are never reviewed.
+func (e *Executor) reviewByHunk(action policy.Action, oldContent, newContent string) bool {
+	return e.cfg.ReviewMode == "hunk" && action == policy.Ask && oldContent != "" && oldContent != newContent && !util.AutoConfirm() && e.interactive()
+}
+
+// reviewHunks lets the user accept, reject or edit every hunk of a change. It returns the content to write,
+// whether anything changed and a description of the outcome for the model.
+func (e *Executor) reviewHunks(path, oldContent, newContent string) (string, bool, string, error) {
+	hunks := util.Hunks(oldContent, newContent)
+	var applied []util.Hunk
+	var outcomes []string
+	partial := false
+
+	decision := ""
+	for i, hunk := range hunks {
+		e.emit(event.Event{Type: event.Diff, Path: path, Text: fmt.Sprintf("Hunk %d/%d of %s:", i+1, len(hunks), path), Diff: hunk.String()})
+
+		choice := decision
+		if choice == "" {
+			choice = e.choose("Apply this hunk?", []string{"yes", "no", "edit", "all", "quit"})
+		}
+		switch choice {
+		case "a":
+			decision = "y"
+			choice = "y"
+		case "q", "":
+			decision = "n"
+			choice = "n"
+		}
+
+		label := fmt.Sprintf("hunk %d (original lines %d-%d)", i+1, hunk.OldStart+1, hunk.OldEnd)
+		switch choice {
+		case "y":
+			applied = append(applied, hunk)
+			outcomes = append(outcomes, label+": applied")
+		case "e":
+			edited, err := util.EditText(hunk.NewText(), "dwight-hunk-*"+filepath.Ext(path))
+			if err != nil {
+				return "", false, "", err
+			}
+			applied = append(applied, hunk.WithNewText(edited))
+			outcomes = append(outcomes, label+": applied with changes by the user")
+			partial = true
+		default:
+			outcomes = append(outcomes, label+": rejected")
+			partial = true
+		}
+	}
+
+	content := util.ApplyHunks(oldContent, applied)
+	if !partial {
+		return content, true, "", nil
+	}
+	summary := fmt.Sprintf("the user reviewed %d hunk(s): %s. Read the file again before changing it further", len(hunks), strings.Join(outcomes, "; "))
+	return content, content != oldContent, summary, nil
+}
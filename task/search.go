@@ -0,0 +1,251 @@
+package task
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/rofleksey/dwight/policy"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	searchDefaultResults = 50
+	searchMaxResults     = 200
+	searchDefaultContext = 2
+	searchMaxContext     = 10
+	// searchMaxFileSize skips generated blobs and data files that are unlikely to be useful matches.
+	searchMaxFileSize = 2 << 20
+	searchMaxLineLen  = 300
+)
+
+type searchArgs struct {
+	Pattern         string `json:"pattern"`
+	Literal         bool   `json:"literal"`
+	CaseInsensitive bool   `json:"case_insensitive"`
+	Path            string `json:"path"`
+	Include         string `json:"include"`
+	ContextLines    *int   `json:"context_lines"`
+	MaxResults      int    `json:"max_results"`
+}
+
+// searchGroup is a run of lines around one or more matches; overlapping contexts are merged.
+type searchGroup struct {
+	file  string
+	first int
+	lines []string
+	hits  map[int]bool
+}
+
+func (e *Executor) handleSearchCode(toolCall openai.ToolCall, messages *[]openai.ChatCompletionMessage) error {
+	var args searchArgs
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+		return err
+	}
+	if args.Path == "" {
+		args.Path = "."
+	}
+
+	e.infof("Search: %q in %s", args.Pattern, args.Path)
+
+	var result string
+	action := e.policy.SearchPath(args.Path)
+	if action == policy.Deny {
+		e.infof("Search denied by policy")
+		result = "ERROR: Searching this path is denied by policy"
+	} else if !e.confirm(action, "Allow this search?") {
+		result = "ERROR: Search denied by user"
+	} else {
+		output, count, err := e.searchCode(args)
+		if err != nil {
+			e.infof("Search failed: %v", err)
+			result = "ERROR: " + err.Error()
+		} else {
+			e.infof("Found %d match(es)", count)
+			result = output
+		}
+	}
+
+	*messages = append(*messages, openai.ChatCompletionMessage{
+		Role:       openai.ChatMessageRoleTool,
+		Content:    result,
+		ToolCallID: toolCall.ID,
+	})
+	return nil
+}
+
+// searchCode walks args.Path and renders matches grep-style: "file:line:text" for matching lines
+// and "file-line-text" for context lines, with "--" between non-adjacent groups.
+func (e *Executor) searchCode(args searchArgs) (string, int, error) {
+	if args.Pattern == "" {
+		return "", 0, errors.New("pattern must not be empty")
+	}
+
+	expr := args.Pattern
+	if args.Literal {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if args.CaseInsensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid regular expression: %w", err)
+	}
+
+	contextLines := searchDefaultContext
+	if args.ContextLines != nil {
+		contextLines = min(max(*args.ContextLines, 0), searchMaxContext)
+	}
+	maxResults := searchDefaultResults
+	if args.MaxResults > 0 {
+		maxResults = min(args.MaxResults, searchMaxResults)
+	}
+
+	ignored, err := e.ignoreMatcher()
+	if err != nil {
+		return "", 0, err
+	}
+
+	root := filepath.Clean(args.Path)
+	if _, err := os.Stat(root); err != nil {
+		return "", 0, err
+	}
+
+	var (
+		out       strings.Builder
+		count     int
+		truncated bool
+	)
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ignored.Ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		if args.Include != "" {
+			if matched, _ := doublestar.Match(args.Include, filepath.ToSlash(path)); !matched {
+				if matched, _ := doublestar.Match(args.Include, d.Name()); !matched {
+					return nil
+				}
+			}
+		}
+
+		// Matches with context can reveal a whole file, so files the policy denies reading are skipped.
+		if e.policy.ReadPath(path) == policy.Deny {
+			return nil
+		}
+
+		groups, hits, err := searchFile(path, re, contextLines, maxResults-count)
+		if err != nil {
+			return nil
+		}
+		for _, g := range groups {
+			writeSearchGroup(&out, g)
+		}
+		count += hits
+		if count >= maxResults {
+			truncated = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	if count == 0 {
+		return "No matches found", 0, nil
+	}
+	if truncated {
+		fmt.Fprintf(&out, "[results truncated at %d matches, narrow the pattern, path or include filter to see more]\n", maxResults)
+	}
+	return out.String(), count, nil
+}
+
+func searchFile(path string, re *regexp.Regexp, contextLines, limit int) ([]*searchGroup, int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if info.Size() > searchMaxFileSize {
+		return nil, 0, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return nil, 0, nil
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	var (
+		groups []*searchGroup
+		hits   int
+	)
+	for i, line := range lines {
+		if hits >= limit {
+			break
+		}
+		if !re.MatchString(line) {
+			continue
+		}
+		hits++
+		first := max(i-contextLines, 0)
+		last := min(i+contextLines, len(lines)-1)
+
+		if n := len(groups); n > 0 {
+			g := groups[n-1]
+			if end := g.first + len(g.lines); first <= end {
+				g.lines = append(g.lines, lines[end:last+1]...)
+				g.hits[i] = true
+				continue
+			}
+		}
+		groups = append(groups, &searchGroup{
+			file:  filepath.ToSlash(path),
+			first: first,
+			lines: append([]string(nil), lines[first:last+1]...),
+			hits:  map[int]bool{i: true},
+		})
+	}
+	return groups, hits, nil
+}
+
+func writeSearchGroup(out *strings.Builder, g *searchGroup) {
+	for i, line := range g.lines {
+		n := g.first + i
+		if len(line) > searchMaxLineLen {
+			line = line[:searchMaxLineLen] + "..."
+		}
+		sep := "-"
+		if g.hits[n] {
+			sep = ":"
+		}
+		fmt.Fprintf(out, "%s%s%d%s%s\n", g.file, sep, n+1, sep, line)
+	}
+	out.WriteString("--\n")
+}
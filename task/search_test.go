@@ -0,0 +1,128 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupSearchProject(t *testing.T) *Executor {
+	t.Helper()
+	setupProject(t)
+	for path, content := range map[string]string{
+		".dwight/policy.yaml": "read:\n  - pattern: \"**/*.env\"\n    action: deny\n",
+		".dwightignore":       "generated/\n",
+		"a.go":                "package a\n\nfunc Alpha() {}\n\n\n\n\n\n\nfunc AlphaTwo() {}\n",
+		"b.txt":               "Alpha in a text file\n",
+		"sub/c.go":            "package sub\n\n// Alpha is mentioned here\nvar x = 1\n",
+		"generated/d.go":      "package generated\n\nfunc Alpha() {}\n",
+		"config.env":          "ALPHA_TOKEN=secret\nline two\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	executor, _, _ := newTestExecutor(t, testConfig())
+	return executor
+}
+
+func TestSearchCode(t *testing.T) {
+	executor := setupSearchProject(t)
+	noContext := 0
+
+	tests := []struct {
+		name      string
+		args      searchArgs
+		want      string
+		wantCount int
+	}{
+		{
+			name: "context groups",
+			args: searchArgs{Pattern: "Alpha", Include: "a.go"},
+			want: "a.go-1-package a\n" +
+				"a.go-2-\n" +
+				"a.go:3:func Alpha() {}\n" +
+				"a.go-4-\n" +
+				"a.go-5-\n" +
+				"--\n" +
+				"a.go-8-\n" +
+				"a.go-9-\n" +
+				"a.go:10:func AlphaTwo() {}\n" +
+				"--\n",
+			wantCount: 2,
+		},
+		{
+			name: "include filter matches names and paths",
+			args: searchArgs{Pattern: "Alpha", Include: "*.go", ContextLines: &noContext},
+			want: "a.go:3:func Alpha() {}\n--\n" +
+				"a.go:10:func AlphaTwo() {}\n--\n" +
+				"sub/c.go:3:// Alpha is mentioned here\n--\n",
+			wantCount: 3,
+		},
+		{
+			name:      "case insensitive literal skips ignored and denied files",
+			args:      searchArgs{Pattern: "alpha", Literal: true, CaseInsensitive: true, Path: ".", ContextLines: &noContext},
+			want:      "a.go:3:func Alpha() {}\n--\na.go:10:func AlphaTwo() {}\n--\nb.txt:1:Alpha in a text file\n--\nsub/c.go:3:// Alpha is mentioned here\n--\n",
+			wantCount: 4,
+		},
+		{
+			name: "result cap",
+			args: searchArgs{Pattern: "Alpha", MaxResults: 2, ContextLines: &noContext},
+			want: "a.go:3:func Alpha() {}\n--\na.go:10:func AlphaTwo() {}\n--\n" +
+				"[results truncated at 2 matches, narrow the pattern, path or include filter to see more]\n",
+			wantCount: 2,
+		},
+		{
+			name:      "no matches",
+			args:      searchArgs{Pattern: "Omega"},
+			want:      "No matches found",
+			wantCount: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.args.Path == "" {
+				tt.args.Path = "."
+			}
+			got, count, err := executor.searchCode(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || count != tt.wantCount {
+				t.Errorf("searchCode() = %d matches:\n%s\nwant %d:\n%s", count, got, tt.wantCount, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchCodeSkipsDeniedFiles(t *testing.T) {
+	executor := setupSearchProject(t)
+
+	got, _, err := executor.searchCode(searchArgs{Pattern: ".", Path: "."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "config.env") || strings.Contains(got, "secret") {
+		t.Errorf("search revealed a file denied by the read policy:\n%s", got)
+	}
+	if !strings.Contains(got, "b.txt:1:") {
+		t.Errorf("search skipped a readable file:\n%s", got)
+	}
+}
+
+func TestSearchCodeErrors(t *testing.T) {
+	executor := setupSearchProject(t)
+	for _, args := range []searchArgs{
+		{Pattern: "", Path: "."},
+		{Pattern: "(unclosed", Path: "."},
+		{Pattern: "x", Path: "missing"},
+	} {
+		if _, _, err := executor.searchCode(args); err == nil {
+			t.Errorf("searchCode(%+v) succeeded, want an error", args)
+		}
+	}
+}
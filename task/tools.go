@@ -1,19 +1,19 @@
 package task
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strings"
 
+	"github.com/rofleksey/dwight/event"
+	"github.com/rofleksey/dwight/policy"
+	"github.com/rofleksey/dwight/sandbox"
+	"github.com/rofleksey/dwight/session"
 	"github.com/rofleksey/dwight/util"
-	"github.com/rofleksey/dwight/util/ignore"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -21,8 +21,18 @@ func (e *Executor) handleToolCall(toolCall openai.ToolCall, messages *[]openai.C
 	switch toolCall.Function.Name {
 	case "get_file_contents":
 		return e.handleGetFileContents(toolCall, messages)
+	case "search_code":
+		return e.handleSearchCode(toolCall, messages)
+	case "list_directory":
+		return e.handleListDirectory(toolCall, messages)
 	case "modify_files":
 		return e.handleModifyFiles(toolCall, messages)
+	case "edit_file":
+		return e.handleEditFile(toolCall, messages)
+	case "delete_files":
+		return e.handleDeleteFiles(toolCall, messages)
+	case "move_files":
+		return e.handleMoveFiles(toolCall, messages)
 	case "run_command":
 		return e.handleRunCommand(toolCall, messages)
 	case "ask_question":
@@ -36,41 +46,65 @@ func (e *Executor) handleToolCall(toolCall openai.ToolCall, messages *[]openai.C
 
 func (e *Executor) handleGetFileContents(toolCall openai.ToolCall, messages *[]openai.ChatCompletionMessage) error {
 	var args struct {
-		Files []string `json:"files"`
+		Files []fileRequest `json:"files"`
 	}
 	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
 		return err
 	}
 
-	ignorePatterns, err := ignore.LoadPatterns()
+	ignored, err := e.ignoreMatcher()
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("AI wants to read these files:\n")
+	e.infof("AI wants to read these files:")
+	contents := make(map[string]string)
+	var pending []fileRequest
+	action := policy.Allow
 	for _, file := range args.Files {
-		fmt.Printf("  - %s\n", file)
+		label := file.key()
+		fileAction := e.policy.ReadPath(file.Path)
+		if fileAction == policy.Deny {
+			e.infof("  - %s (denied by policy)", label)
+			contents[label] = "ERROR: Reading this file is denied by policy"
+			continue
+		}
+		action = policy.Stricter(action, fileAction)
+		e.infof("  - %s", label)
+		pending = append(pending, file)
 	}
 
-	contents := make(map[string]string)
-	if util.ConfirmAction("Allow reading these files?") {
-		for _, file := range args.Files {
-			if util.IsIgnored(file, ignorePatterns) {
-				contents[file] = "ERROR: Access to this file is forbidden by ignore patterns"
-				continue
-			}
+	approved := len(pending) > 0
+	if approved {
+		if action == policy.Allow {
+			e.infof("Reading allowed by policy")
+		} else {
+			approved = e.confirm(action, "Allow reading these files?")
+		}
+	}
 
-			content, err := os.ReadFile(file)
-			if err != nil {
-				contents[file] = "ERROR: " + err.Error()
-			} else {
-				contents[file] = string(content)
-			}
+	for _, file := range pending {
+		key := file.key()
+		if !approved {
+			contents[key] = "ERROR: File reading denied by user"
+			continue
 		}
-	} else {
-		for _, file := range args.Files {
-			contents[file] = "ERROR: File reading denied by user"
+		if ignored.IgnoredPath(file.Path) {
+			contents[key] = "ERROR: Access to this file is forbidden by ignore patterns"
+			continue
 		}
+
+		content, err := os.ReadFile(file.Path)
+		if err != nil {
+			contents[key] = "ERROR: " + err.Error()
+			continue
+		}
+		rendered, err := renderLines(string(content), file, e.cfg.ReadMaxBytes)
+		if err != nil {
+			contents[key] = "ERROR: " + err.Error()
+			continue
+		}
+		contents[key] = rendered
 	}
 
 	contentJSON, err := json.Marshal(contents)
@@ -86,6 +120,38 @@ func (e *Executor) handleGetFileContents(toolCall openai.ToolCall, messages *[]o
 	return nil
 }
 
+func (e *Executor) handleListDirectory(toolCall openai.ToolCall, messages *[]openai.ChatCompletionMessage) error {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+		return err
+	}
+	if args.Path == "" {
+		args.Path = "."
+	}
+
+	e.infof("List directory: %s", args.Path)
+
+	var listing string
+	if e.policy.ReadPath(args.Path) == policy.Deny {
+		e.infof("Listing denied by policy")
+		listing = "ERROR: Listing this directory is denied by policy"
+	} else if output, err := e.listDirectory(args.Path); err != nil {
+		e.infof("Listing failed: %v", err)
+		listing = "ERROR: " + err.Error()
+	} else {
+		listing = output
+	}
+
+	*messages = append(*messages, openai.ChatCompletionMessage{
+		Role:       openai.ChatMessageRoleTool,
+		Content:    listing,
+		ToolCallID: toolCall.ID,
+	})
+	return nil
+}
+
 func (e *Executor) handleModifyFiles(toolCall openai.ToolCall, messages *[]openai.ChatCompletionMessage) error {
 	var args struct {
 		Files []struct {
@@ -99,36 +165,77 @@ func (e *Executor) handleModifyFiles(toolCall openai.ToolCall, messages *[]opena
 
 	results := make([]string, 0, len(args.Files))
 	for _, file := range args.Files {
-		fmt.Printf("Modifying: %s\n", file.FilePath)
+		e.infof("Modifying: %s", file.FilePath)
+
+		target, err := e.resolveWritePath(file.FilePath)
+		if err != nil {
+			e.infof("Refusing to write: %v", err)
+			results = append(results, fmt.Sprintf("%s: ERROR: %v", file.FilePath, err))
+			continue
+		}
+		file.FilePath = target
+
+		action := e.policy.WritePath(file.FilePath)
+		if action == policy.Deny {
+			e.infof("Writing this file is denied by policy")
+			results = append(results, fmt.Sprintf("%s: ERROR: Writing this file is denied by policy", file.FilePath))
+			continue
+		}
 
 		var oldContent string
+		existed := false
 		if existing, err := os.ReadFile(file.FilePath); err == nil {
 			oldContent = string(existing)
+			existed = true
 		}
 
-		if oldContent != "" && oldContent != file.Content {
-			diff, err := util.UnifiedDiffColored(oldContent, file.Content, file.FilePath)
+		if err := checkPlaceholders(oldContent, file.Content); err != nil {
+			e.infof("Refusing to write redaction placeholders")
+			results = append(results, fmt.Sprintf("%s: ERROR: %v", file.FilePath, err))
+			continue
+		}
+
+		content, apply, review := file.Content, false, ""
+		if e.reviewByHunk(action, oldContent, file.Content) {
+			var err error
+			content, apply, review, err = e.reviewHunks(file.FilePath, oldContent, file.Content)
 			if err != nil {
-				return err
+				e.infof("Review failed: %v", err)
+				results = append(results, fmt.Sprintf("%s: ERROR: %v", file.FilePath, err))
+				continue
 			}
-
-			if strings.TrimSpace(diff) != "" {
-				fmt.Println("Proposed changes:")
-				fmt.Println(diff)
+		} else {
+			if oldContent != "" && oldContent != file.Content {
+				if err := e.showDiff(file.FilePath, "Proposed changes:", oldContent, file.Content); err != nil {
+					return err
+				}
+			} else if oldContent == "" {
+				e.infof("Creating new file")
 			}
-		} else if oldContent == "" {
-			fmt.Println("Creating new file")
+			apply = e.confirm(action, "Apply these changes?")
 		}
 
-		if util.ConfirmAction("Apply these changes?") {
+		if apply {
+			e.ensureCheckpoint()
 			if err := os.MkdirAll(filepath.Dir(file.FilePath), 0755); err != nil {
 				return err
 			}
-			if err := os.WriteFile(file.FilePath, []byte(file.Content), 0644); err != nil {
+			if err := os.WriteFile(file.FilePath, []byte(content), 0644); err != nil {
 				return err
 			}
-			results = append(results, fmt.Sprintf("%s: Updated", file.FilePath))
-			fmt.Printf("Updated %s\n", file.FilePath)
+			e.infof("Updated %s", file.FilePath)
+			if existed {
+				e.recordChange(session.ChangeModified, file.FilePath, "")
+			} else {
+				e.recordChange(session.ChangeCreated, file.FilePath, "")
+			}
+			if review != "" {
+				results = append(results, fmt.Sprintf("%s: Partially updated, %s", file.FilePath, review))
+			} else {
+				results = append(results, fmt.Sprintf("%s: Updated", file.FilePath))
+			}
+		} else if review != "" {
+			results = append(results, fmt.Sprintf("%s: Skipped, %s", file.FilePath, review))
 		} else {
 			results = append(results, fmt.Sprintf("%s: Skipped", file.FilePath))
 		}
@@ -142,43 +249,294 @@ func (e *Executor) handleModifyFiles(toolCall openai.ToolCall, messages *[]opena
 	return nil
 }
 
+func (e *Executor) handleEditFile(toolCall openai.ToolCall, messages *[]openai.ChatCompletionMessage) error {
+	var args struct {
+		FilePath string             `json:"file_path"`
+		Edits    []util.Replacement `json:"edits"`
+	}
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+		return err
+	}
+
+	e.infof("Editing: %s", args.FilePath)
+
+	result, err := e.applyEdits(args.FilePath, args.Edits)
+	if err != nil {
+		e.infof("Edit failed: %v", err)
+		result = fmt.Sprintf("%s: ERROR: %v", args.FilePath, err)
+	}
+
+	*messages = append(*messages, openai.ChatCompletionMessage{
+		Role:       openai.ChatMessageRoleTool,
+		Content:    result,
+		ToolCallID: toolCall.ID,
+	})
+	return nil
+}
+
+func (e *Executor) applyEdits(filePath string, edits []util.Replacement) (string, error) {
+	filePath, err := e.resolveWritePath(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	action := e.policy.WritePath(filePath)
+	if action == policy.Deny {
+		return "", fmt.Errorf("writing this file is denied by policy")
+	}
+	for _, edit := range edits {
+		if err := checkPlaceholders(edit.OldString, edit.NewString); err != nil {
+			return "", err
+		}
+	}
+
+	existing, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	oldContent := string(existing)
+
+	newContent, err := util.ApplyReplacements(oldContent, edits)
+	if err != nil {
+		return "", err
+	}
+	if newContent == oldContent {
+		return fmt.Sprintf("%s: No changes", filePath), nil
+	}
+
+	if e.reviewByHunk(action, oldContent, newContent) {
+		content, apply, review, err := e.reviewHunks(filePath, oldContent, newContent)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case review == "":
+			return e.writeEdited(filePath, content, fmt.Sprintf("%s: Updated (%d edits applied)", filePath, len(edits)))
+		case !apply:
+			return fmt.Sprintf("%s: Skipped, %s", filePath, review), nil
+		default:
+			return e.writeEdited(filePath, content, fmt.Sprintf("%s: Partially updated, %s", filePath, review))
+		}
+	}
+
+	if err := e.showDiff(filePath, "Proposed changes:", oldContent, newContent); err != nil {
+		return "", err
+	}
+
+	if !e.confirm(action, "Apply these changes?") {
+		return fmt.Sprintf("%s: Skipped", filePath), nil
+	}
+	return e.writeEdited(filePath, newContent, fmt.Sprintf("%s: Updated (%d edits applied)", filePath, len(edits)))
+}
+
+func (e *Executor) writeEdited(filePath, content, result string) (string, error) {
+	e.ensureCheckpoint()
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	e.infof("Updated %s", filePath)
+	e.recordChange(session.ChangeModified, filePath, "")
+	return result, nil
+}
+
+func (e *Executor) handleDeleteFiles(toolCall openai.ToolCall, messages *[]openai.ChatCompletionMessage) error {
+	var args struct {
+		Paths []string `json:"paths"`
+	}
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+		return err
+	}
+
+	results := make([]string, 0, len(args.Paths))
+	for _, path := range args.Paths {
+		e.infof("Deleting: %s", path)
+
+		result, err := e.deleteFile(path)
+		if err != nil {
+			e.infof("Delete failed: %v", err)
+			result = fmt.Sprintf("%s: ERROR: %v", path, err)
+		}
+		results = append(results, result)
+	}
+
+	*messages = append(*messages, openai.ChatCompletionMessage{
+		Role:       openai.ChatMessageRoleTool,
+		Content:    strings.Join(results, "\n"),
+		ToolCallID: toolCall.ID,
+	})
+	return nil
+}
+
+func (e *Executor) deleteFile(path string) (string, error) {
+	target, err := e.resolveEntryPath(path)
+	if err != nil {
+		return "", err
+	}
+	action := e.policy.WritePath(target)
+	if action == policy.Deny {
+		return "", fmt.Errorf("writing this file is denied by policy")
+	}
+
+	info, err := os.Lstat(target)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory, delete the files in it instead", target)
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, _ := os.Readlink(target)
+		e.infof("%s is a symlink to %s, only the link is deleted", target, link)
+	} else if content, err := os.ReadFile(target); err == nil {
+		if err := e.showDiff(target, "Proposed changes:", string(content), ""); err != nil {
+			return "", err
+		}
+	}
+
+	if !e.confirm(action, "Delete this file?") {
+		return fmt.Sprintf("%s: Skipped", target), nil
+	}
+
+	e.ensureCheckpoint()
+	if err := os.Remove(target); err != nil {
+		return "", err
+	}
+	removeEmptyParents(filepath.Dir(target))
+	e.infof("Deleted %s", target)
+	e.recordChange(session.ChangeDeleted, target, "")
+	return fmt.Sprintf("%s: Deleted", target), nil
+}
+
+func (e *Executor) handleMoveFiles(toolCall openai.ToolCall, messages *[]openai.ChatCompletionMessage) error {
+	var args struct {
+		Moves []struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		} `json:"moves"`
+	}
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+		return err
+	}
+
+	results := make([]string, 0, len(args.Moves))
+	for _, move := range args.Moves {
+		e.infof("Moving: %s -> %s", move.From, move.To)
+
+		result, err := e.moveFile(move.From, move.To)
+		if err != nil {
+			e.infof("Move failed: %v", err)
+			result = fmt.Sprintf("%s -> %s: ERROR: %v", move.From, move.To, err)
+		}
+		results = append(results, result)
+	}
+
+	*messages = append(*messages, openai.ChatCompletionMessage{
+		Role:       openai.ChatMessageRoleTool,
+		Content:    strings.Join(results, "\n"),
+		ToolCallID: toolCall.ID,
+	})
+	return nil
+}
+
+func (e *Executor) moveFile(from, to string) (string, error) {
+	source, err := e.resolveEntryPath(from)
+	if err != nil {
+		return "", err
+	}
+	destination, err := e.resolveEntryPath(to)
+	if err != nil {
+		return "", err
+	}
+	action := e.policy.MovePath(source, destination)
+	if action == policy.Deny {
+		return "", fmt.Errorf("moving this file is denied by policy")
+	}
+
+	info, err := os.Lstat(source)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory, move the files in it instead", source)
+	}
+	if _, err := os.Lstat(destination); err == nil {
+		return "", fmt.Errorf("%s already exists", destination)
+	}
+
+	if !e.confirm(action, fmt.Sprintf("Move %s to %s?", source, destination)) {
+		return fmt.Sprintf("%s -> %s: Skipped", source, destination), nil
+	}
+
+	e.ensureCheckpoint()
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(source, destination); err != nil {
+		return "", err
+	}
+	removeEmptyParents(filepath.Dir(source))
+	e.infof("Moved %s to %s", source, destination)
+	e.recordChange(session.ChangeMoved, destination, source)
+	return fmt.Sprintf("%s -> %s: Moved", source, destination), nil
+}
+
 func (e *Executor) handleRunCommand(toolCall openai.ToolCall, messages *[]openai.ChatCompletionMessage) error {
 	var args struct {
-		Command string `json:"command"`
+		Command    string `json:"command"`
+		WorkingDir string `json:"working_dir"`
 	}
 	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
 		return err
 	}
 
-	fmt.Printf("Execute: %s\n", args.Command)
+	if args.WorkingDir != "" {
+		e.infof("Execute (in %s): %s", args.WorkingDir, args.Command)
+	} else {
+		e.infof("Execute: %s", args.Command)
+	}
 
 	resp := map[string]interface{}{
 		"command":   args.Command,
 		"confirmed": false,
 	}
 
-	if util.ConfirmAction("Run this command?") {
+	action := e.policy.Command(args.Command)
+	if action == policy.Deny {
+		e.infof("Command denied by policy")
+		resp["message"] = "Command denied by policy, do not retry it in another form"
+	} else if e.confirm(action, "Run this command?") {
 		resp["confirmed"] = true
 
-		cmd := exec.Command("sh", "-c", args.Command)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		result, err := sandbox.Run(ctx, e.commandPolicy(), args.Command, args.WorkingDir, event.Writer(sessionSink{e}, "stdout"), event.Writer(sessionSink{e}, "stderr"))
+		interrupted := ctx.Err() != nil
+		stop()
 
-		var stdoutBuf, stderrBuf bytes.Buffer
-		cmd.Stdout = io.MultiWriter(os.Stdout, &stdoutBuf)
-		cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
-
-		err := cmd.Run()
-		exitCode := 0
-		if err != nil {
-			var ee *exec.ExitError
-			if errors.As(err, &ee) {
-				exitCode = ee.ExitCode()
+		switch {
+		case err != nil:
+			e.infof("Command failed: %v", err)
+			resp["error"] = err.Error()
+		default:
+			if result.ExitCode != 0 && !result.TimedOut && !interrupted {
+				e.infof("Command failed: exit code %d", result.ExitCode)
+			}
+			resp["exit_code"] = result.ExitCode
+			e.recordCommand(args.Command, result.ExitCode, result.TimedOut)
+			resp["stdout"] = result.Stdout
+			resp["stderr"] = result.Stderr
+			if result.Truncated {
+				resp["output_truncated"] = true
+			}
+			if result.TimedOut {
+				e.infof("Command timed out after %s and was killed", e.cfg.CommandTimeout)
+				resp["timed_out"] = true
+				resp["message"] = fmt.Sprintf("Command was killed after exceeding the %s timeout", e.cfg.CommandTimeout)
+			} else if interrupted {
+				e.infof("Command interrupted by user")
+				resp["message"] = "Command was interrupted by the user"
 			}
-			fmt.Printf("Command failed: %v\n", err)
 		}
-
-		resp["exit_code"] = exitCode
-		resp["stdout"] = stdoutBuf.String()
-		resp["stderr"] = stderrBuf.String()
 	} else {
 		resp["message"] = "Command not executed"
 	}
@@ -193,6 +551,17 @@ func (e *Executor) handleRunCommand(toolCall openai.ToolCall, messages *[]openai
 	return nil
 }
 
+func (e *Executor) commandPolicy() sandbox.Policy {
+	return sandbox.Policy{
+		Root:         ".",
+		Timeout:      e.cfg.CommandTimeout,
+		MaxOutput:    e.cfg.CommandMaxOutput,
+		Jail:         e.cfg.CommandJail,
+		EnvAllowlist: e.cfg.CommandEnvAllowlist,
+		Mode:         e.cfg.CommandSandbox,
+	}
+}
+
 func (e *Executor) handleAskQuestion(toolCall openai.ToolCall, messages *[]openai.ChatCompletionMessage) error {
 	var args struct {
 		Question string `json:"question"`
@@ -201,23 +570,24 @@ func (e *Executor) handleAskQuestion(toolCall openai.ToolCall, messages *[]opena
 		return err
 	}
 
-	fmt.Printf("Question: %s\n", args.Question)
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("Your answer: ")
-	answer, _ := reader.ReadString('\n')
-
-	*messages = append(*messages, openai.ChatCompletionMessage{
-		Role:       openai.ChatMessageRoleTool,
-		Content:    fmt.Sprintf("Answer: %s", strings.TrimSpace(answer)),
-		ToolCallID: toolCall.ID,
-	})
-	return nil
-}
+	var answer string
+	if e.interactive() {
+		e.emit(event.Event{Type: event.QuestionAsked, Prompt: args.Question})
+		answer = util.ReadLine()
+	} else if scripted, ok := e.answers.Answer(args.Question); ok {
+		e.emit(event.Event{Type: event.QuestionAsked, Prompt: args.Question, Answer: scripted})
+		answer = scripted
+	} else if e.answers.unanswered == UnansweredAssume {
+		e.emit(event.Event{Type: event.QuestionAsked, Prompt: args.Question, Answer: assumptionAnswer})
+		answer = assumptionAnswer
+	} else {
+		e.requireInput("the model asked: %s", args.Question)
+		return nil
+	}
 
-func (e *Executor) handleTaskComplete(toolCall openai.ToolCall, messages *[]openai.ChatCompletionMessage) error {
 	*messages = append(*messages, openai.ChatCompletionMessage{
 		Role:       openai.ChatMessageRoleTool,
-		Content:    "Task completion acknowledged",
+		Content:    fmt.Sprintf("Answer: %s", answer),
 		ToolCallID: toolCall.ID,
 	})
 	return nil
@@ -0,0 +1,88 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rofleksey/dwight/event"
+	"github.com/rofleksey/dwight/session"
+	"github.com/sashabaranov/go-openai"
+)
+
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// recordUsage adds the usage of a single model request to the session totals and reports a running summary.
+func (e *Executor) recordUsage(usage openai.Usage) {
+	cached := 0
+	if usage.PromptTokensDetails != nil {
+		cached = usage.PromptTokensDetails.CachedTokens
+	}
+
+	total := &e.session.Usage
+	total.Requests++
+	total.PromptTokens += usage.PromptTokens
+	total.CachedTokens += cached
+	total.CompletionTokens += usage.CompletionTokens
+
+	stats := &event.UsageStats{PromptTokens: usage.PromptTokens, CachedTokens: cached, CompletionTokens: usage.CompletionTokens}
+	requestCost := ""
+	if cost, ok := e.requestCost(usage.PromptTokens, cached, usage.CompletionTokens); ok {
+		total.Cost += cost
+		total.CostKnown = true
+		requestCost = fmt.Sprintf(", $%.4f", cost)
+		stats.Cost, stats.CostKnown = cost, true
+	}
+
+	e.emit(event.Event{
+		Type: event.Usage,
+		Text: fmt.Sprintf("Tokens: %d in (%d cached), %d out%s | task total: %s",
+			usage.PromptTokens, cached, usage.CompletionTokens, requestCost, formatUsage(*total)),
+		Usage: stats,
+	})
+}
+
+// requestCost prices a request with the configured per-million-token rates of the model.
+// Cached prompt tokens fall back to the regular input rate when no cached rate is configured.
+func (e *Executor) requestCost(prompt, cached, completion int) (float64, bool) {
+	price, ok := e.cfg.PriceFor(e.cfg.Model)
+	if !ok {
+		return 0, false
+	}
+
+	cachedRate := price.CachedInput
+	if cachedRate == 0 {
+		cachedRate = price.Input
+	}
+
+	cost := float64(prompt-cached)*price.Input + float64(cached)*cachedRate + float64(completion)*price.Output
+	return cost / 1_000_000, true
+}
+
+func (e *Executor) checkBudget() error {
+	usage := e.session.Usage
+	if e.cfg.MaxTokens > 0 && usage.TotalTokens() >= e.cfg.MaxTokens {
+		return fmt.Errorf("%w: used %d of %d tokens", ErrBudgetExceeded, usage.TotalTokens(), e.cfg.MaxTokens)
+	}
+	if e.cfg.MaxCost > 0 && usage.Cost >= e.cfg.MaxCost {
+		return fmt.Errorf("%w: spent $%.4f of $%.4f", ErrBudgetExceeded, usage.Cost, e.cfg.MaxCost)
+	}
+	return nil
+}
+
+func (e *Executor) warnUnpricedBudget() {
+	if e.cfg.MaxCost <= 0 {
+		return
+	}
+	if _, ok := e.cfg.PriceFor(e.cfg.Model); !ok {
+		e.warnf("Warning: max_cost is set but no price is configured for model %s, cost budget will not be enforced", e.cfg.Model)
+	}
+}
+
+func formatUsage(usage session.Usage) string {
+	text := fmt.Sprintf("%d tokens (%d in, %d cached, %d out)",
+		usage.TotalTokens(), usage.PromptTokens, usage.CachedTokens, usage.CompletionTokens)
+	if usage.CostKnown {
+		text += fmt.Sprintf(", $%.4f", usage.Cost)
+	}
+	return text
+}
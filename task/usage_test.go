@@ -0,0 +1,154 @@
+package task
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/rofleksey/dwight/api"
+	"github.com/rofleksey/dwight/config"
+	"github.com/rofleksey/dwight/event"
+	"github.com/rofleksey/dwight/session"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestRequestCost(t *testing.T) {
+	tests := []struct {
+		name   string
+		prices []config.Price
+		want   float64
+		ok     bool
+	}{
+		{name: "unpriced model"},
+		{name: "other model priced", prices: []config.Price{{Model: "other", Input: 1, Output: 1}}},
+		{
+			name:   "cached rate",
+			prices: []config.Price{{Model: "test-model", Input: 2, CachedInput: 0.5, Output: 10}},
+			// 600k uncached, 400k cached and 100k completion tokens.
+			want: 0.6*2 + 0.4*0.5 + 0.1*10,
+			ok:   true,
+		},
+		{
+			name:   "cached tokens fall back to the input rate",
+			prices: []config.Price{{Model: "TEST-MODEL", Input: 2, Output: 10}},
+			want:   1.0*2 + 0.1*10,
+			ok:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupProject(t)
+			cfg := testConfig()
+			cfg.Prices = tt.prices
+			executor, _, _ := newTestExecutor(t, cfg)
+
+			got, ok := executor.requestCost(1_000_000, 400_000, 100_000)
+			if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("requestCost() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRecordUsage(t *testing.T) {
+	setupProject(t)
+	cfg := testConfig()
+	cfg.Prices = []config.Price{{Model: "test-model", Input: 1, Output: 2}}
+	executor, _, events := newTestExecutor(t, cfg)
+	executor.session = session.New("count tokens")
+
+	executor.recordUsage(openai.Usage{PromptTokens: 1000, CompletionTokens: 100, PromptTokensDetails: &openai.PromptTokensDetails{CachedTokens: 400}})
+	executor.recordUsage(openai.Usage{PromptTokens: 2000, CompletionTokens: 200})
+
+	usage := executor.session.Usage
+	want := session.Usage{Requests: 2, PromptTokens: 3000, CachedTokens: 400, CompletionTokens: 300, CostKnown: true}
+	cost := usage.Cost
+	usage.Cost = 0
+	if usage != want || math.Abs(cost-0.0036) > 1e-9 {
+		t.Errorf("usage = %+v with cost %v, want %+v with cost 0.0036", usage, cost, want)
+	}
+
+	last, ok := events.last(event.Usage)
+	if !ok || last.Usage == nil {
+		t.Fatalf("usage event = %+v, %v", last, ok)
+	}
+	if stats := *last.Usage; stats.PromptTokens != 2000 || stats.CachedTokens != 0 || stats.CompletionTokens != 200 || !stats.CostKnown || math.Abs(stats.Cost-0.0024) > 1e-9 {
+		t.Errorf("usage stats = %+v, want the last request only", stats)
+	}
+	if !strings.Contains(last.Text, "task total: 3300 tokens") {
+		t.Errorf("usage text = %q, want the task total", last.Text)
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxTokens int
+		maxCost   float64
+		usage     session.Usage
+		wantErr   string
+	}{
+		{name: "no limits", usage: session.Usage{PromptTokens: 1_000_000, Cost: 100}},
+		{name: "below the token limit", maxTokens: 1000, usage: session.Usage{PromptTokens: 900, CompletionTokens: 99}},
+		{name: "token limit reached", maxTokens: 1000, usage: session.Usage{PromptTokens: 900, CompletionTokens: 100}, wantErr: "used 1000 of 1000 tokens"},
+		{name: "below the cost limit", maxCost: 1, usage: session.Usage{Cost: 0.99}},
+		{name: "cost limit exceeded", maxCost: 1, usage: session.Usage{Cost: 1.5}, wantErr: "spent $1.5000 of $1.0000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupProject(t)
+			cfg := testConfig()
+			cfg.MaxTokens, cfg.MaxCost = tt.maxTokens, tt.maxCost
+			executor, _, _ := newTestExecutor(t, cfg)
+			executor.session = session.New("spend")
+			executor.session.Usage = tt.usage
+
+			err := executor.checkBudget()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkBudget() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrBudgetExceeded) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkBudget() = %v, want ErrBudgetExceeded containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteStopsWhenCostBudgetExceeded(t *testing.T) {
+	setupProject(t)
+	cfg := testConfig()
+	cfg.MaxCost = 0.01
+	cfg.Prices = []config.Price{{Model: "test-model", Input: 10, Output: 30}}
+	executor, provider, _ := newTestExecutor(t, cfg,
+		api.ScriptedResponse{ToolCalls: readFile("a.txt").ToolCalls, Usage: &openai.Usage{PromptTokens: 1000, CompletionTokens: 100}},
+		complete(session.CompletionSuccess),
+	)
+
+	err := executor.Execute("spend money")
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Execute() error = %v, want ErrBudgetExceeded", err)
+	}
+	if n := len(provider.Requests()); n != 1 {
+		t.Errorf("model was called %d times, want no request after the budget was spent", n)
+	}
+	report := executor.Report()
+	if report.Status != session.StatusBudgetExceeded || report.ExitCode != ExitBudgetExceeded || math.Abs(report.Usage.Cost-0.013) > 1e-9 {
+		t.Errorf("report = %s (exit %d), cost %v, want %s (exit %d), cost 0.013", report.Status, report.ExitCode, report.Usage.Cost, session.StatusBudgetExceeded, ExitBudgetExceeded)
+	}
+}
+
+func TestWarnUnpricedBudget(t *testing.T) {
+	setupProject(t)
+	cfg := testConfig()
+	cfg.MaxCost = 1
+	executor, _, events := newTestExecutor(t, cfg)
+
+	executor.warnUnpricedBudget()
+	if last, ok := events.last(event.Message); !ok || last.Level != event.LevelWarning || !strings.Contains(last.Text, "cost budget will not be enforced") {
+		t.Errorf("warning = %+v, %v, want a notice that max_cost cannot be enforced", last, ok)
+	}
+}
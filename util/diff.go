@@ -6,9 +6,8 @@ import (
 	difflib "github.com/pmezard/go-difflib/difflib"
 )
 
-// UnifiedDiffColored returns a colorized unified diff between oldContent and newContent for the given filePath.
-// Lines added are green, removed are red, hunk headers are cyan, and file headers are dim gray.
-func UnifiedDiffColored(oldContent, newContent, filePath string) (string, error) {
+// UnifiedDiff returns a plain unified diff between oldContent and newContent for the given filePath.
+func UnifiedDiff(oldContent, newContent, filePath string) (string, error) {
 	ud := difflib.UnifiedDiff{
 		A:        difflib.SplitLines(oldContent),
 		B:        difflib.SplitLines(newContent),
@@ -17,14 +16,11 @@ func UnifiedDiffColored(oldContent, newContent, filePath string) (string, error)
 		Context:  3,
 	}
 
-	text, err := difflib.GetUnifiedDiffString(ud)
-	if err != nil {
-		return "", err
-	}
-	return colorizeDiff(text), nil
+	return difflib.GetUnifiedDiffString(ud)
 }
 
-func colorizeDiff(unified string) string {
+// ColorizeDiff colors the lines of a unified diff for the terminal.
+func ColorizeDiff(unified string) string {
 	var b strings.Builder
 	lines := strings.Split(unified, "\n")
 	for i, line := range lines {
@@ -0,0 +1,80 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Replacement describes a single exact string substitution within a file.
+type Replacement struct {
+	OldString string `json:"old_string"`
+	NewString string `json:"new_string"`
+}
+
+// ApplyReplacements applies replacements to content in order. Every OldString must match
+// exactly once in the content produced by the preceding replacements, otherwise an error
+// describing the mismatch is returned and content is left untouched.
+func ApplyReplacements(content string, replacements []Replacement) (string, error) {
+	result := content
+	for i, r := range replacements {
+		if r.OldString == "" {
+			return "", fmt.Errorf("edit %d: old_string must not be empty", i+1)
+		}
+
+		count := strings.Count(result, r.OldString)
+		switch {
+		case count == 0:
+			return "", fmt.Errorf("edit %d: old_string not found%s", i+1, describeNearMatch(result, r.OldString))
+		case count > 1:
+			return "", fmt.Errorf("edit %d: old_string matches %d times (at lines %s), include more surrounding context to make it unique",
+				i+1, count, strings.Join(matchLines(result, r.OldString), ", "))
+		}
+
+		result = strings.Replace(result, r.OldString, r.NewString, 1)
+	}
+	return result, nil
+}
+
+func matchLines(content, needle string) []string {
+	var lines []string
+	offset := 0
+	for {
+		idx := strings.Index(content[offset:], needle)
+		if idx < 0 {
+			return lines
+		}
+		pos := offset + idx
+		lines = append(lines, fmt.Sprint(strings.Count(content[:pos], "\n")+1))
+		offset = pos + len(needle)
+	}
+}
+
+// describeNearMatch hints at a likely cause when the needle only matches after ignoring indentation.
+func describeNearMatch(content, needle string) string {
+	needleLines := strings.Split(strings.TrimSpace(needle), "\n")
+	contentLines := strings.Split(content, "\n")
+	first := strings.TrimSpace(needleLines[0])
+
+	for i := range contentLines {
+		if strings.TrimSpace(contentLines[i]) != first || i+len(needleLines) > len(contentLines) {
+			continue
+		}
+		matched := true
+		for j, line := range needleLines {
+			if strings.TrimSpace(contentLines[i+j]) != strings.TrimSpace(line) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return fmt.Sprintf(" (a match ignoring whitespace exists at line %d, check indentation and line endings)", i+1)
+		}
+	}
+
+	for i, line := range contentLines {
+		if first != "" && strings.TrimSpace(line) == first {
+			return fmt.Sprintf(" (first line matches line %d, but the following lines differ)", i+1)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyReplacements(t *testing.T) {
+	content := "func a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 1\n}\n"
+	tests := []struct {
+		name         string
+		replacements []Replacement
+		want         string
+		wantErr      string
+	}{
+		{
+			name:         "single edit",
+			replacements: []Replacement{{OldString: "func a() {\n\treturn 1", NewString: "func a() {\n\treturn 2"}},
+			want:         "func a() {\n\treturn 2\n}\n\nfunc b() {\n\treturn 1\n}\n",
+		},
+		{
+			name: "edits apply to the result of the previous ones",
+			replacements: []Replacement{
+				{OldString: "func a()", NewString: "func c()"},
+				{OldString: "func c() {\n\treturn 1", NewString: "func c() {\n\treturn 3"},
+			},
+			want: "func c() {\n\treturn 3\n}\n\nfunc b() {\n\treturn 1\n}\n",
+		},
+		{
+			name:         "deletion",
+			replacements: []Replacement{{OldString: "\nfunc b() {\n\treturn 1\n}\n", NewString: ""}},
+			want:         "func a() {\n\treturn 1\n}\n",
+		},
+		{
+			name:         "empty old string",
+			replacements: []Replacement{{OldString: "", NewString: "x"}},
+			wantErr:      "edit 1: old_string must not be empty",
+		},
+		{
+			name:         "ambiguous",
+			replacements: []Replacement{{OldString: "return 1", NewString: "return 2"}},
+			wantErr:      "edit 1: old_string matches 2 times (at lines 2, 6)",
+		},
+		{
+			name:         "not found",
+			replacements: []Replacement{{OldString: "func d()", NewString: "func e()"}},
+			wantErr:      "edit 1: old_string not found",
+		},
+		{
+			name:         "indentation differs",
+			replacements: []Replacement{{OldString: "func b() {\n    return 1\n}", NewString: ""}},
+			wantErr:      "a match ignoring whitespace exists at line 5",
+		},
+		{
+			name:         "following lines differ",
+			replacements: []Replacement{{OldString: "func b() {\n\treturn 2\n}", NewString: ""}},
+			wantErr:      "first line matches line 5, but the following lines differ",
+		},
+		{
+			name: "later edit fails",
+			replacements: []Replacement{
+				{OldString: "func a()", NewString: "func c()"},
+				{OldString: "func a()", NewString: "func d()"},
+			},
+			wantErr: "edit 2: old_string not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyReplacements(content, tt.replacements)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ApplyReplacements() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ApplyReplacements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	difflib "github.com/pmezard/go-difflib/difflib"
+)
+
+// Hunk is one group of changes between two versions of a file, with up to three lines of context.
+// It replaces the old lines [OldStart, OldEnd) with NewLines. Lines keep their line endings.
+type Hunk struct {
+	OldStart int
+	OldEnd   int
+	OldLines []string
+	NewLines []string
+	// newStart is the first line of the hunk in the new version, used for the header.
+	newStart int
+}
+
+// Hunks splits the changes from oldContent to newContent into hunks, like the ones shown by UnifiedDiff.
+func Hunks(oldContent, newContent string) []Hunk {
+	a := splitLines(oldContent)
+	b := splitLines(newContent)
+
+	var hunks []Hunk
+	for _, group := range difflib.NewMatcher(a, b).GetGroupedOpCodes(3) {
+		first, last := group[0], group[len(group)-1]
+		hunks = append(hunks, Hunk{
+			OldStart: first.I1,
+			OldEnd:   last.I2,
+			OldLines: a[first.I1:last.I2],
+			NewLines: b[first.J1:last.J2],
+			newStart: first.J1,
+		})
+	}
+	return hunks
+}
+
+// String returns the hunk in unified diff format.
+func (h Hunk) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@", h.OldStart+1, len(h.OldLines), h.newStart+1, len(h.NewLines))
+	write := func(prefix string, lines []string) {
+		for _, line := range lines {
+			b.WriteString("\n" + prefix + strings.TrimSuffix(line, "\n"))
+			if !strings.HasSuffix(line, "\n") {
+				b.WriteString("\n\\ No newline at end of file")
+			}
+		}
+	}
+	for _, op := range difflib.NewMatcher(h.OldLines, h.NewLines).GetOpCodes() {
+		if op.Tag == 'e' {
+			write(" ", h.OldLines[op.I1:op.I2])
+			continue
+		}
+		write("-", h.OldLines[op.I1:op.I2])
+		write("+", h.NewLines[op.J1:op.J2])
+	}
+	return b.String()
+}
+
+// NewText returns the new version of the hunk's lines as text.
+func (h Hunk) NewText() string {
+	return strings.Join(h.NewLines, "")
+}
+
+// WithNewText returns the hunk with its new lines replaced by text, e.g. after the user edited them.
+func (h Hunk) WithNewText(text string) Hunk {
+	h.NewLines = splitLines(text)
+	return h
+}
+
+// ApplyHunks returns oldContent with the given hunks applied. Hunks must come from Hunks(oldContent, ...)
+// and be in order; changes of hunks left out are not applied.
+func ApplyHunks(oldContent string, hunks []Hunk) string {
+	a := splitLines(oldContent)
+	var b strings.Builder
+	pos := 0
+	for _, h := range hunks {
+		b.WriteString(strings.Join(a[pos:h.OldStart], ""))
+		text := h.NewText()
+		// An edited hunk in the middle of the file must not swallow the line break before the next line.
+		if h.OldEnd < len(a) && text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		b.WriteString(text)
+		pos = h.OldEnd
+	}
+	b.WriteString(strings.Join(a[pos:], ""))
+	return b.String()
+}
+
+// splitLines splits text into lines that keep their "\n", so joining them gives back text exactly.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// EditText opens text in $EDITOR (vi if unset) and returns the edited result.
+func EditText(text, pattern string) (string, error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.WriteString(text); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", file.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor failed: %w", err)
+	}
+
+	edited, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(edited), nil
+}
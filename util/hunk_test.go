@@ -0,0 +1,86 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numbered returns n lines "line 1\n" to "line n\n".
+func numbered(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
+
+func TestHunks(t *testing.T) {
+	oldContent := numbered(20)
+	newContent := strings.Replace(strings.Replace(oldContent, "line 2\n", "line two\n", 1), "line 18\n", "", 1)
+
+	hunks := Hunks(oldContent, newContent)
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2: %v", len(hunks), hunks)
+	}
+	if h := hunks[0]; h.OldStart != 0 || h.OldEnd != 5 || h.NewText() != "line 1\nline two\nline 3\nline 4\nline 5\n" {
+		t.Errorf("first hunk = [%d, %d) %q", h.OldStart, h.OldEnd, h.NewText())
+	}
+	want := "@@ -15,6 +15,5 @@\n line 15\n line 16\n line 17\n-line 18\n line 19\n line 20"
+	if got := hunks[1].String(); got != want {
+		t.Errorf("second hunk:\n%s\nwant:\n%s", got, want)
+	}
+	if got := Hunks(oldContent, oldContent); len(got) != 0 {
+		t.Errorf("unchanged content has hunks: %v", got)
+	}
+}
+
+func TestHunkStringMissingNewline(t *testing.T) {
+	hunks := Hunks("a\nb", "a\nc")
+	if len(hunks) != 1 {
+		t.Fatalf("got %d hunks, want 1", len(hunks))
+	}
+	want := "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file"
+	if got := hunks[0].String(); got != want {
+		t.Errorf("hunk:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestApplyHunks(t *testing.T) {
+	oldContent := numbered(20)
+	newContent := strings.Replace(strings.Replace(oldContent, "line 2\n", "line two\n", 1), "line 18\n", "", 1)
+	hunks := Hunks(oldContent, newContent)
+
+	tests := []struct {
+		name  string
+		hunks []Hunk
+		want  string
+	}{
+		{name: "all hunks", hunks: hunks, want: newContent},
+		{name: "no hunks", hunks: nil, want: oldContent},
+		{name: "first hunk", hunks: hunks[:1], want: strings.Replace(oldContent, "line 2\n", "line two\n", 1)},
+		{name: "second hunk", hunks: hunks[1:], want: strings.Replace(oldContent, "line 18\n", "", 1)},
+		{
+			name:  "edited hunk without trailing newline",
+			hunks: []Hunk{hunks[0].WithNewText("line 1\nline 2 edited")},
+			want:  strings.Replace(oldContent, "line 2\nline 3\nline 4\nline 5\n", "line 2 edited\n", 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ApplyHunks(oldContent, tt.hunks); got != tt.want {
+				t.Errorf("ApplyHunks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyHunksKeepsMissingFinalNewline(t *testing.T) {
+	oldContent, newContent := "a\nb", "a\nb\nc"
+	if got := ApplyHunks(oldContent, Hunks(oldContent, newContent)); got != newContent {
+		t.Errorf("ApplyHunks() = %q, want %q", got, newContent)
+	}
+	if got := ApplyHunks(newContent, Hunks(newContent, oldContent)); got != oldContent {
+		t.Errorf("ApplyHunks() = %q, want %q", got, oldContent)
+	}
+}
@@ -2,56 +2,207 @@ package ignore
 
 import (
 	"bufio"
-	_ "embed"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 )
 
-const ignoreFilename = ".dwightignore"
+const (
+	ignoreFilename    = ".dwightignore"
+	gitignoreFilename = ".gitignore"
+)
 
+// defaultPatterns use gitignore syntax, like every other pattern source.
 var defaultPatterns = []string{
-	".idea/**",
+	".idea/",
 	".*ignore",
-	".git/**",
-	"LICENSE",
+	".git/",
+	".dwight/",
+	"/LICENSE",
 	"go.sum",
 }
 
-func LoadPatterns() ([]string, error) {
-	var patterns []string
+// Matcher decides whether project paths are ignored, following gitignore semantics: the last matching
+// pattern wins, "!" re-includes, a trailing "/" only matches directories, patterns containing a "/" are
+// anchored to the directory of the file that defines them, and ignoring a directory ignores its contents.
+// Ignore files inside the project are read lazily, once per directory, when a path below it is matched.
+type Matcher struct {
+	base      string
+	filenames []string
+	// prefix is the current directory relative to the base all patterns are resolved against.
+	prefix []string
+
+	mu   sync.Mutex
+	dirs map[string]*level
+}
+
+// level holds the patterns that apply inside one directory: those of its parents and its own ignore files.
+type level struct {
+	patterns []gitignore.Pattern
+	matcher  gitignore.Matcher
+	// ignored is set when the directory itself, or one of its parents, is ignored.
+	ignored bool
+}
+
+// Load collects patterns, lowest priority first, from the built-in defaults, ~/.dwightignore and,
+// when useGitignore is set, .git/info/exclude and .gitignore files, followed by .dwightignore files in
+// the project. Within a directory .dwightignore is applied after .gitignore so it can re-include files.
+// Patterns are resolved relative to the enclosing git repository, or the current directory without one.
+func Load(useGitignore bool) (*Matcher, error) {
+	return load(useGitignore, defaultPatterns)
+}
+
+// LoadWithoutDefaults is Load without the built-in defaults, leaving only the patterns the user wrote.
+func LoadWithoutDefaults(useGitignore bool) (*Matcher, error) {
+	return load(useGitignore, nil)
+}
+
+func load(useGitignore bool, defaults []string) (*Matcher, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	base := cwd
+	gitRoot, inRepo := findGitRoot(cwd)
+	if inRepo && useGitignore {
+		base = gitRoot
+	}
 
-	patterns = append(patterns, defaultPatterns...)
+	var patterns []gitignore.Pattern
+	for _, line := range defaults {
+		patterns = append(patterns, gitignore.ParsePattern(line, nil))
+	}
 
-	home, err := os.UserHomeDir()
-	if err == nil {
-		homeIgnore, err := readIgnoreFile(filepath.Join(home, ignoreFilename))
-		if err == nil {
-			patterns = append(patterns, homeIgnore...)
+	if home, err := os.UserHomeDir(); err == nil {
+		if lines, err := readIgnoreFile(filepath.Join(home, ignoreFilename)); err == nil {
+			for _, line := range lines {
+				patterns = append(patterns, gitignore.ParsePattern(line, nil))
+			}
 		}
 	}
 
-	err = filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
+	filenames := []string{ignoreFilename}
+	if useGitignore {
+		filenames = []string{gitignoreFilename, ignoreFilename}
+		if inRepo {
+			patterns = append(patterns, readPatterns(filepath.Join(gitRoot, ".git", "info", "exclude"), nil)...)
 		}
-		if info.Name() == ignoreFilename {
-			dir := filepath.Dir(path)
-			filePatterns, err := readIgnoreFile(path)
-			if err == nil {
-				for _, pattern := range filePatterns {
-					if dir != "." {
-						patterns = append(patterns, filepath.Join(dir, pattern))
-					} else {
-						patterns = append(patterns, pattern)
-					}
-				}
-			}
+	}
+
+	// Ignore files of parent directories between the repository root and the current directory apply too.
+	prefix := splitPath(mustRel(base, cwd))
+	for i := 0; i < len(prefix); i++ {
+		dir := filepath.Join(append([]string{base}, prefix[:i]...)...)
+		for _, name := range filenames {
+			patterns = append(patterns, readPatterns(filepath.Join(dir, name), prefix[:i])...)
 		}
-		return nil
-	})
+	}
+
+	m := &Matcher{
+		base:      cwd,
+		filenames: filenames,
+		prefix:    prefix,
+		dirs:      make(map[string]*level),
+	}
+	m.dirs["."] = m.newLevel(nil, patterns)
+	return m, nil
+}
+
+// Ignored reports whether path, relative to the current directory, is ignored. Paths outside
+// the current directory are never ignored.
+func (m *Matcher) Ignored(path string, isDir bool) bool {
+	path = filepath.Clean(path)
+	if filepath.IsAbs(path) {
+		var err error
+		if path, err = filepath.Rel(m.base, path); err != nil {
+			return false
+		}
+	}
+	if path == "." {
+		return false
+	}
+	if path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return false
+	}
 
-	return patterns, err
+	parts := splitPath(path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parent := m.level(parts[:len(parts)-1])
+	return parent.ignored || parent.matcher.Match(m.domain(parts), isDir)
+}
+
+// level returns the patterns of the directory dir, reading the ignore files of it and its parents
+// on first use.
+func (m *Matcher) level(dir []string) *level {
+	key := strings.Join(dir, "/")
+	if key == "" {
+		key = "."
+	}
+	if l, ok := m.dirs[key]; ok {
+		return l
+	}
+
+	parent := m.level(dir[:len(dir)-1])
+	var l *level
+	if parent.ignored || parent.matcher.Match(m.domain(dir), true) {
+		l = &level{patterns: parent.patterns, matcher: parent.matcher, ignored: true}
+	} else {
+		l = m.newLevel(dir, parent.patterns)
+	}
+	m.dirs[key] = l
+	return l
+}
+
+// newLevel appends the patterns of the ignore files in dir to inherited and builds their matcher.
+func (m *Matcher) newLevel(dir []string, inherited []gitignore.Pattern) *level {
+	patterns := inherited[:len(inherited):len(inherited)]
+	for _, name := range m.filenames {
+		path := filepath.Join(m.base, filepath.Join(dir...), name)
+		patterns = append(patterns, readPatterns(path, m.domain(dir))...)
+	}
+	return &level{patterns: patterns, matcher: gitignore.NewMatcher(patterns)}
+}
+
+// domain prefixes parts, relative to the current directory, with the path of the current directory
+// relative to the pattern base.
+func (m *Matcher) domain(parts []string) []string {
+	return append(append([]string(nil), m.prefix...), parts...)
+}
+
+// IgnoredPath is like Ignored but looks up whether path is a directory itself.
+func (m *Matcher) IgnoredPath(path string) bool {
+	info, err := os.Stat(path)
+	return m.Ignored(path, err == nil && info.IsDir())
+}
+
+func findGitRoot(dir string) (string, bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+func readPatterns(path string, domain []string) []gitignore.Pattern {
+	lines, err := readIgnoreFile(path)
+	if err != nil {
+		return nil
+	}
+	patterns := make([]gitignore.Pattern, 0, len(lines))
+	for _, line := range lines {
+		patterns = append(patterns, gitignore.ParsePattern(line, domain))
+	}
+	return patterns
 }
 
 func readIgnoreFile(path string) ([]string, error) {
@@ -64,11 +215,27 @@ func readIgnoreFile(path string) ([]string, error) {
 	var patterns []string
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" && !strings.HasPrefix(line, "#") {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "#") {
 			patterns = append(patterns, line)
 		}
 	}
 
 	return patterns, scanner.Err()
 }
+
+func mustRel(base, target string) string {
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return "."
+	}
+	return rel
+}
+
+func splitPath(path string) []string {
+	path = filepath.ToSlash(filepath.Clean(path))
+	if path == "." || path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,141 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupProject creates the files in a temporary git repository, changes into it and isolates the home
+// directory.
+func setupProject(t *testing.T, files map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("HOME", t.TempDir())
+	if err := os.Mkdir(".git", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	setupProject(t, map[string]string{
+		".gitignore":            "*.log\n/build\ndocs/generated\ntmp/\n!keep.log\nvendor/\n",
+		"sub/.gitignore":        "/local.txt\nout/\n",
+		"sub/.dwightignore":     "!debug.log\n",
+		"vendor/.gitignore":     "!*\n",
+		"sub/nested/.gitignore": "",
+	})
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		// Defaults.
+		{path: ".git", isDir: true, want: true},
+		{path: "LICENSE", want: true},
+		{path: "sub/LICENSE", want: false},
+		{path: "sub/go.sum", want: true},
+		// Unanchored patterns match at any depth.
+		{path: "app.log", want: true},
+		{path: "sub/nested/app.log", want: true},
+		// "!" re-includes, .dwightignore is applied after .gitignore of the same directory.
+		{path: "keep.log", want: false},
+		{path: "sub/debug.log", want: false},
+		{path: "debug.log", want: true},
+		// A leading "/" or a "/" inside anchors to the directory of the ignore file.
+		{path: "build", isDir: true, want: true},
+		{path: "sub/build", isDir: true, want: false},
+		{path: "docs/generated", isDir: true, want: true},
+		{path: "sub/docs/generated", isDir: true, want: false},
+		{path: "sub/local.txt", want: true},
+		{path: "local.txt", want: false},
+		{path: "sub/nested/local.txt", want: false},
+		// A trailing "/" only matches directories.
+		{path: "tmp", isDir: true, want: true},
+		{path: "tmp", want: false},
+		{path: "sub/out", isDir: true, want: true},
+		{path: "out", isDir: true, want: false},
+		// Ignoring a directory ignores its contents, which cannot be re-included.
+		{path: "tmp/file.go", want: true},
+		{path: "sub/out/a/b.go", want: true},
+		{path: "vendor/lib.go", want: true},
+		// Paths outside of the current directory are never ignored.
+		{path: "../app.log", want: false},
+		{path: "main.go", want: false},
+	}
+
+	m, err := Load(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if got := m.Ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoredWithoutGitignore(t *testing.T) {
+	setupProject(t, map[string]string{
+		".gitignore":    "*.log\n",
+		".dwightignore": "secret/\n",
+	})
+
+	m, err := Load(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Ignored("app.log", false) {
+		t.Error("app.log is ignored although .gitignore files are disabled")
+	}
+	if !m.Ignored("secret", true) {
+		t.Error("secret/ from .dwightignore is not ignored")
+	}
+}
+
+func TestIgnoredFromSubdirectory(t *testing.T) {
+	setupProject(t, map[string]string{
+		".gitignore":         "/sub/generated.go\n*.tmp\n",
+		"sub/.gitignore":     "!keep.tmp\n",
+		"sub/generated.go":   "",
+		"sub/pkg/.gitignore": "/only-here\n",
+	})
+	t.Chdir("sub")
+
+	m, err := Load(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "generated.go", want: true},
+		{path: "a.tmp", want: true},
+		{path: "keep.tmp", want: false},
+		{path: "pkg/only-here", want: true},
+		{path: "only-here", want: false},
+	}
+	for _, tt := range tests {
+		if got := m.Ignored(tt.path, false); got != tt.want {
+			t.Errorf("Ignored(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	abs, err := filepath.Abs("a.tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Ignored(abs, false) {
+		t.Errorf("Ignored(%q) = false, want absolute paths resolved against the current directory", abs)
+	}
+}
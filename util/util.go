@@ -5,31 +5,34 @@ import (
 	"fmt"
 	"os"
 	"strings"
-
-	"github.com/bmatcuk/doublestar/v4"
 )
 
 var autoConfirm bool
 
+// stdin is shared by all prompts, so input buffered for one prompt is not lost to the next.
+var stdin = bufio.NewReader(os.Stdin)
+
 func SetAutoConfirm(v bool) { autoConfirm = v }
 
-func IsIgnored(file string, patterns []string) bool {
-	for _, pattern := range patterns {
-		matched, err := doublestar.Match(pattern, file)
-		if err == nil && matched {
-			return true
-		}
-	}
-	return false
-}
+func AutoConfirm() bool { return autoConfirm }
 
 func ConfirmAction(prompt string) bool {
 	if autoConfirm {
 		fmt.Printf("%s (y/N): y\n", prompt)
 		return true
 	}
-	reader := bufio.NewReader(os.Stdin)
 	fmt.Printf("%s (y/N): ", prompt)
-	response, _ := reader.ReadString('\n')
-	return strings.ToLower(strings.TrimSpace(response)) == "y"
+	return strings.ToLower(ReadLine()) == "y"
+}
+
+// ReadLine reads a line from stdin without surrounding whitespace.
+func ReadLine() string {
+	line, _ := ReadLineOK()
+	return line
+}
+
+// ReadLineOK is like ReadLine but also reports whether stdin is still open.
+func ReadLineOK() (string, bool) {
+	line, err := stdin.ReadString('\n')
+	return strings.TrimSpace(line), err == nil
 }
@@ -0,0 +1,296 @@
+package vcs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/rofleksey/dwight/util"
+)
+
+// excluded lists paths that belong to dwight itself and must never end up in commits.
+var excluded = []string{".dwight/"}
+
+type Repository struct {
+	repo *git.Repository
+	wt   *git.Worktree
+	root string
+}
+
+type Change struct {
+	Path   string
+	Status string
+	Diff   string
+}
+
+func Open() (*Repository, error) {
+	repo, err := git.PlainOpenWithOptions(".", &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("error opening git repository: %w", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range excluded {
+		wt.Excludes = append(wt.Excludes, gitignore.ParsePattern(p, nil))
+	}
+	return &Repository{repo: repo, wt: wt, root: wt.Filesystem.Root()}, nil
+}
+
+func (r *Repository) CurrentBranch() (string, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	if !head.Name().IsBranch() {
+		return "", errors.New("HEAD is detached, check out a branch first")
+	}
+	return head.Name().Short(), nil
+}
+
+func (r *Repository) RemoteURL(name string) (string, error) {
+	remote, err := r.repo.Remote(name)
+	if err != nil {
+		return "", fmt.Errorf("error reading remote %s: %w", name, err)
+	}
+	urls := remote.Config().URLs
+	if len(urls) == 0 {
+		return "", fmt.Errorf("remote %s has no URL", name)
+	}
+	return urls[0], nil
+}
+
+// RelativePath converts a path relative to the current directory to a slash-separated path relative to
+// the repository root.
+func (r *Repository) RelativePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(r.root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the repository", path)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Changes returns uncommitted changes of paths relative to HEAD, with plain unified diffs.
+// paths are relative to the repository root; paths without changes are left out.
+func (r *Repository) Changes(paths []string) ([]Change, error) {
+	status, err := r.wt.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var headTree *object.Tree
+	if head, err := r.repo.Head(); err == nil {
+		commit, err := r.repo.CommitObject(head.Hash())
+		if err != nil {
+			return nil, err
+		}
+		if headTree, err = commit.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	changed := make([]string, 0, len(paths))
+	for _, path := range paths {
+		s, ok := status[path]
+		if !ok || s.Worktree == git.Unmodified && s.Staging == git.Unmodified {
+			continue
+		}
+		if isExcluded(path) {
+			continue
+		}
+		changed = append(changed, path)
+	}
+	sort.Strings(changed)
+
+	changes := make([]Change, 0, len(changed))
+	for _, path := range changed {
+		oldContent := ""
+		if headTree != nil {
+			if f, err := headTree.File(path); err == nil {
+				oldContent, _ = f.Contents()
+			}
+		}
+
+		newContent := ""
+		changeStatus := "modified"
+		if data, err := os.ReadFile(filepath.Join(r.root, filepath.FromSlash(path))); err == nil {
+			newContent = string(data)
+		} else {
+			changeStatus = "deleted"
+		}
+		if status[path].Worktree == git.Untracked || status[path].Staging == git.Added {
+			changeStatus = "added"
+		}
+
+		diff, err := util.UnifiedDiff(oldContent, newContent, path)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, Change{Path: path, Status: changeStatus, Diff: diff})
+	}
+	return changes, nil
+}
+
+// CreateBranch creates a branch at HEAD and checks it out, keeping working tree changes.
+func (r *Repository) CreateBranch(name string) error {
+	return r.wt.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(name),
+		Create: true,
+		Keep:   true,
+	})
+}
+
+// ReturnToBranch checks out branch again without touching the working tree, so changes committed on
+// another branch since then show up as uncommitted changes again.
+func (r *Repository) ReturnToBranch(name string) error {
+	ref := plumbing.NewBranchReferenceName(name)
+	target, err := r.repo.Reference(ref, true)
+	if err != nil {
+		return err
+	}
+	if err := r.repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, ref)); err != nil {
+		return err
+	}
+	return r.wt.Reset(&git.ResetOptions{Commit: target.Hash(), Mode: git.MixedReset})
+}
+
+// CommitPaths stages paths, relative to the repository root, including deletions, and commits them.
+// Other changes of the working tree are left uncommitted. It refuses to commit if other changes are
+// already staged, as they would end up in the commit as well.
+func (r *Repository) CommitPaths(message string, paths []string) (string, error) {
+	status, err := r.wt.Status()
+	if err != nil {
+		return "", err
+	}
+	included := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		included[path] = true
+	}
+	for path, s := range status {
+		if !included[path] && s.Staging != git.Unmodified && s.Staging != git.Untracked {
+			return "", fmt.Errorf("%s has staged changes that are not part of the task, unstage them first", path)
+		}
+	}
+
+	for _, path := range paths {
+		if _, err := r.wt.Add(filepath.FromSlash(path)); err != nil {
+			return "", fmt.Errorf("error staging %s: %w", path, err)
+		}
+	}
+	hash, err := r.wt.Commit(message, &git.CommitOptions{})
+	if err != nil {
+		return "", fmt.Errorf("error committing changes: %w", err)
+	}
+	return hash.String(), nil
+}
+
+// Push pushes the branch to the remote. HTTP(S) remotes authenticate with the bearer token,
+// other transports fall back to go-git defaults (e.g. ssh-agent).
+func (r *Repository) Push(remote, branch, token string) error {
+	remoteURL, err := r.RemoteURL(remote)
+	if err != nil {
+		return err
+	}
+
+	var auth transport.AuthMethod
+	if strings.HasPrefix(remoteURL, "http://") || strings.HasPrefix(remoteURL, "https://") {
+		auth = &http.TokenAuth{Token: token}
+	}
+
+	ref := plumbing.NewBranchReferenceName(branch)
+	err = r.repo.Push(&git.PushOptions{
+		RemoteName: remote,
+		RefSpecs:   []config.RefSpec{config.RefSpec(ref + ":" + ref)},
+		Auth:       auth,
+		Progress:   io.Discard,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("error pushing branch %s: %w", branch, err)
+	}
+	return nil
+}
+
+// RecentlyChanged returns the files touched by the last commits reachable from HEAD, most recent first,
+// as paths relative to the current directory. Files outside the current directory are left out.
+func (r *Repository) RecentlyChanged(commits int) ([]string, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	iter, err := r.repo.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var paths []string
+	for i := 0; i < commits; i++ {
+		commit, err := iter.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		tree, err := commit.Tree()
+		if err != nil {
+			return nil, err
+		}
+		var parentTree *object.Tree
+		if parent, err := commit.Parent(0); err == nil {
+			if parentTree, err = parent.Tree(); err != nil {
+				return nil, err
+			}
+		}
+
+		changes, err := object.DiffTree(parentTree, tree)
+		if err != nil {
+			return nil, err
+		}
+		for _, change := range changes {
+			name := change.To.Name
+			if name == "" {
+				name = change.From.Name
+			}
+			rel, err := filepath.Rel(cwd, filepath.Join(r.root, filepath.FromSlash(name)))
+			if err != nil || strings.HasPrefix(rel, "..") || seen[rel] {
+				continue
+			}
+			seen[rel] = true
+			paths = append(paths, rel)
+		}
+	}
+	return paths, nil
+}
+
+func isExcluded(path string) bool {
+	for _, p := range excluded {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
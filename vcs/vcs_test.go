@@ -0,0 +1,201 @@
+package vcs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// setupRepository creates a repository with a committed a.txt and b.txt and changes into it.
+func setupRepository(t *testing.T) *Repository {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.User.Name, cfg.User.Email = "Test", "test@example.com"
+	if err := repo.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	writeFile(t, "a.txt", "a")
+	writeFile(t, "b.txt", "b")
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.AddGlob("*.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Commit("initial", &git.CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func headFiles(t *testing.T, r *Repository) map[string]string {
+	t.Helper()
+	head, err := r.repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		files[f.Name], err = f.Contents()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestCommitPathsLeavesUnrelatedChanges(t *testing.T) {
+	r := setupRepository(t)
+
+	writeFile(t, "a.txt", "changed by task")
+	writeFile(t, "new.txt", "created by task")
+	if err := os.Remove("b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, "user.txt", "work in progress of the user")
+	writeFile(t, ".dwight/sessions/s.json", "{}")
+
+	if _, err := r.CommitPaths("task", []string{"a.txt", "new.txt", "b.txt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"a.txt": "changed by task", "new.txt": "created by task"}
+	if got := headFiles(t, r); !reflect.DeepEqual(got, want) {
+		t.Errorf("committed files = %v, want %v", got, want)
+	}
+	status, err := r.wt.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.IsUntracked("user.txt") {
+		t.Errorf("user.txt should stay untracked, status:\n%s", status)
+	}
+}
+
+func TestCommitPathsRefusesUnrelatedStagedChanges(t *testing.T) {
+	r := setupRepository(t)
+
+	writeFile(t, "a.txt", "changed by task")
+	writeFile(t, "b.txt", "staged by the user")
+	if _, err := r.wt.Add("b.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := r.CommitPaths("task", []string{"a.txt"})
+	if err == nil || !strings.Contains(err.Error(), "b.txt") {
+		t.Fatalf("error = %v, want a refusal naming b.txt", err)
+	}
+}
+
+func TestChangesOnlyReportsPaths(t *testing.T) {
+	r := setupRepository(t)
+
+	writeFile(t, "a.txt", "changed by task")
+	writeFile(t, "new.txt", "created")
+	writeFile(t, "user.txt", "user")
+
+	changes, err := r.Changes([]string{"a.txt", "new.txt", "b.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Path+":"+c.Status)
+	}
+	sort.Strings(got)
+	if want := []string{"a.txt:modified", "new.txt:added"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+}
+
+func TestReturnToBranchKeepsWorkingTree(t *testing.T) {
+	r := setupRepository(t)
+	base, err := r.CurrentBranch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := headFiles(t, r)
+
+	writeFile(t, "a.txt", "changed by task")
+	if err := r.CreateBranch("dwight/task"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CommitPaths("task", []string{"a.txt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.ReturnToBranch(base); err != nil {
+		t.Fatal(err)
+	}
+
+	if branch, _ := r.CurrentBranch(); branch != base {
+		t.Errorf("current branch = %s, want %s", branch, base)
+	}
+	if got := headFiles(t, r); !reflect.DeepEqual(got, before) {
+		t.Errorf("%s moved: files = %v, want %v", base, got, before)
+	}
+	data, err := os.ReadFile("a.txt")
+	if err != nil || string(data) != "changed by task" {
+		t.Errorf("a.txt = %q, %v, want the task's change kept", data, err)
+	}
+	changes, err := r.Changes([]string{"a.txt"})
+	if err != nil || len(changes) != 1 {
+		t.Errorf("changes = %v, %v, want a.txt as uncommitted change", changes, err)
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	r := setupRepository(t)
+	if err := os.Mkdir("sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir("sub")
+
+	if got, err := r.RelativePath("x/y.go"); err != nil || got != "sub/x/y.go" {
+		t.Errorf("RelativePath = %q, %v", got, err)
+	}
+	if _, err := r.RelativePath("../../outside"); err == nil {
+		t.Error("RelativePath accepted a path outside the repository")
+	}
+}